@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAccept(t *testing.T) {
+	h := RequireAccept("application/json", "application/xml")(okHandler)
+	tests := []struct {
+		name       string
+		accept     string
+		wantStatus int
+	}{
+		{"missing", "", http.StatusNotAcceptable},
+		{"wildcard only", "*/*", http.StatusNotAcceptable},
+		{"type wildcard only", "application/*", http.StatusNotAcceptable},
+		{"unsupported", "text/html", http.StatusNotAcceptable},
+		{"valid", "application/json", http.StatusOK},
+		{"valid among others", "text/html, application/xml;q=0.9, */*;q=0.1", http.StatusOK},
+		{"case-insensitive", "Application/JSON", http.StatusOK},
+		{"refused with q=0", "application/json;q=0", http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminToken is the bearer token the /admin endpoints require. While it is
+// empty every admin request is refused.
+var AdminToken = os.Getenv("ADMIN_TOKEN")
+
+// RequireAdmin rejects requests that don't carry AdminToken as a bearer
+// token.
+func RequireAdmin(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) != 1 {
+			writeError(w, r, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// adminRouter returns the operator endpoints mounted under /admin.
+func adminRouter() chi.Router {
+	r := chi.NewRouter()
+	r.Use(RequireAdmin)
+	r.Use(ForceJSON)
+	r.Post("/maintenance", maintenanceHandler)
+	r.Get("/inflight", inflightHandler)
+	r.Post("/flags/{name}", flagHandler)
+	return r
+}
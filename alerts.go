@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// OnServerError is called by AlertOnServerError after every response with a
+// 5xx status. It does nothing by default; point it at your alerting.
+var OnServerError = func(r *http.Request, status int) {}
+
+// AlertOnServerError calls OnServerError once a request has been answered
+// with a 5xx, whether it came from a handler, the Handler error path or
+// Recover. It must run outside the recoverer to see panics.
+func AlertOnServerError(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			if ww.Status() >= 500 {
+				OnServerError(r, ww.Status())
+			}
+		}()
+		next.ServeHTTP(ww, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAlertOnServerError(t *testing.T) {
+	orig := OnServerError
+	t.Cleanup(func() { OnServerError = orig })
+
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantAlert int
+	}{
+		{"500", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) }, http.StatusInternalServerError},
+		{"503 from a Handler error", Handler(func(w http.ResponseWriter, r *http.Request) error {
+			return &HTTPError{Status: http.StatusServiceUnavailable, Message: "down"}
+		}).ServeHTTP, http.StatusServiceUnavailable},
+		{"panic caught by Recover", Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })).ServeHTTP, http.StatusInternalServerError},
+		{"404", func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }, 0},
+		{"200", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerted := 0
+			OnServerError = func(r *http.Request, status int) { alerted = status }
+			AlertOnServerError(tt.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if alerted != tt.wantAlert {
+				t.Errorf("alerted with %d, want %d", alerted, tt.wantAlert)
+			}
+		})
+	}
+}
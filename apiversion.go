@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type apiVersionKey struct{}
+
+// APIVersion lets clients pin the API version they were written against with
+// the X-API-Version header. Requests without it get defaultVersion; requests
+// asking for a version not in supported get a 400 listing the supported
+// ones. The resolved version is echoed back in the response and available
+// to handlers through APIVersionFromContext.
+func APIVersion(supported []string, defaultVersion string) func(next http.Handler) http.Handler {
+	known := make(map[string]bool, len(supported))
+	for _, v := range supported {
+		known[v] = true
+	}
+	list := strings.Join(supported, ", ")
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			version := strings.TrimSpace(r.Header.Get("X-API-Version"))
+			if version == "" {
+				version = defaultVersion
+			}
+			if !known[version] {
+				writeError(w, r, http.StatusBadRequest, "unsupported API version; supported versions are "+list)
+				return
+			}
+			w.Header().Set("X-API-Version", version)
+			ctx := context.WithValue(r.Context(), apiVersionKey{}, version)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// APIVersionFromContext returns the API version resolved by APIVersion, or
+// "" if it didn't run.
+func APIVersionFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(apiVersionKey{}).(string)
+	return v
+}
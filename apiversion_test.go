@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIVersion(t *testing.T) {
+	var got string
+	h := APIVersion([]string{"2023-01-01", "2024-06-01"}, "2024-06-01")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = APIVersionFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		want       string
+	}{
+		{"missing uses default", "", http.StatusOK, "2024-06-01"},
+		{"valid", "2023-01-01", http.StatusOK, "2023-01-01"},
+		{"surrounding space", " 2023-01-01 ", http.StatusOK, "2023-01-01"},
+		{"unsupported", "2020-01-01", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-API-Version", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got != tt.want {
+				t.Errorf("version in context = %q, want %q", got, tt.want)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var env errorResponse
+				json.Unmarshal(rec.Body.Bytes(), &env)
+				if want := "unsupported API version; supported versions are 2023-01-01, 2024-06-01"; env.Error != want {
+					t.Errorf("error = %q, want %q", env.Error, want)
+				}
+				return
+			}
+			if echoed := rec.Header().Get("X-API-Version"); echoed != tt.want {
+				t.Errorf("echoed X-API-Version = %q, want %q", echoed, tt.want)
+			}
+		})
+	}
+}
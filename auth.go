@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+type userKey struct{}
+
+// Authenticate resolves the user behind HTTP Basic credentials checked
+// against creds, a map of username to password, and stores it for
+// UserFromContext. Requests without credentials continue anonymously;
+// requests with wrong ones get a 401.
+func Authenticate(realm string, creds map[string]string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			want, known := creds[user]
+			if !known || subtle.ConstantTimeCompare([]byte(pass), []byte(want)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+				writeError(w, r, http.StatusUnauthorized, "invalid credentials")
+				return
+			}
+			ctx := context.WithValue(r.Context(), userKey{}, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// UserFromContext returns the user Authenticate resolved, if any.
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok
+}
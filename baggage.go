@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type baggageKey struct{}
+
+// Baggage copies the listed incoming headers, such as X-Correlation-ID, into
+// the request context. StructuredLogger logs them when Baggage runs before
+// it, and HTTPClient forwards them on outbound requests.
+func Baggage(headers []string) func(next http.Handler) http.Handler {
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		names[i] = http.CanonicalHeaderKey(h)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			baggage := make(map[string]string)
+			for _, name := range names {
+				if v := r.Header.Get(name); v != "" {
+					baggage[name] = v
+				}
+			}
+			if len(baggage) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), baggageKey{}, baggage)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// BaggageFromContext returns the headers Baggage captured, keyed by their
+// canonical name. The map must not be modified.
+func BaggageFromContext(ctx context.Context) map[string]string {
+	baggage, _ := ctx.Value(baggageKey{}).(map[string]string)
+	return baggage
+}
+
+// HTTPClient is the client handlers should use for outbound requests, built
+// with the incoming request's context so that its baggage is forwarded and
+// its deadline (see RequestBudget) bounds the call.
+var HTTPClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: &outboundTransport{base: http.DefaultTransport},
+}
+
+// outboundTransport refuses to start calls once the request's budget is
+// spent, and adds the context's baggage headers to outbound requests that
+// don't already set them.
+type outboundTransport struct {
+	base http.RoundTripper
+}
+
+func (t *outboundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if RemainingBudget(req.Context()) == 0 {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, context.DeadlineExceeded
+	}
+
+	baggage := BaggageFromContext(req.Context())
+	if len(baggage) == 0 {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	for name, v := range baggage {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, v)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBaggage(t *testing.T) {
+	var upstreamHeader http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeader = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name        string
+		header      map[string]string
+		wantBaggage map[string]string
+	}{
+		{
+			name:        "configured headers",
+			header:      map[string]string{"X-Correlation-Id": "corr-1", "x-tenant-id": "acme"},
+			wantBaggage: map[string]string{"X-Correlation-Id": "corr-1", "X-Tenant-Id": "acme"},
+		},
+		{
+			name:        "others ignored",
+			header:      map[string]string{"X-Correlation-Id": "corr-2", "X-Secret": "hunter2"},
+			wantBaggage: map[string]string{"X-Correlation-Id": "corr-2"},
+		},
+		{
+			name:        "none",
+			header:      map[string]string{"X-Secret": "hunter2"},
+			wantBaggage: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			var got map[string]string
+			h := Baggage([]string{"X-Correlation-ID", "x-tenant-id"})(StructuredLogger(logger)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = BaggageFromContext(r.Context())
+					req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+					resp, err := HTTPClient.Do(req)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					resp.Body.Close()
+				})))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !reflect.DeepEqual(got, tt.wantBaggage) {
+				t.Errorf("BaggageFromContext = %v, want %v", got, tt.wantBaggage)
+			}
+			var line map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+				t.Fatalf("log line %q: %v", logs.String(), err)
+			}
+			if _, ok := line["X-Secret"]; ok {
+				t.Error("unlisted header was logged")
+			}
+			if upstreamHeader.Get("X-Secret") != "" {
+				t.Error("unlisted header was forwarded")
+			}
+			for name, v := range tt.wantBaggage {
+				if line[name] != v {
+					t.Errorf("logged %s = %v, want %q", name, line[name], v)
+				}
+				if got := upstreamHeader.Get(name); got != v {
+					t.Errorf("forwarded %s = %q, want %q", name, got, v)
+				}
+			}
+		})
+	}
+}
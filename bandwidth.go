@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// BandwidthLimit caps the total response bytes served to all clients within
+// each window. Once the cap is reached, new requests get a 503 until the
+// window resets; responses already under way are allowed to finish, so the
+// cap can be overshot by the requests in flight. It is a crude guard for
+// environments that pay for, or are limited in, egress.
+func BandwidthLimit(bytesPerWindow int64, window time.Duration) func(next http.Handler) http.Handler {
+	var (
+		served      atomic.Int64
+		windowStart atomic.Int64
+	)
+	windowStart.Store(time.Now().UnixNano())
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now().UnixNano()
+			start := windowStart.Load()
+			if now-start >= int64(window) && windowStart.CompareAndSwap(start, now) {
+				served.Store(0)
+				start = now
+			}
+
+			if served.Load() >= bytesPerWindow {
+				reset := time.Unix(0, start).Add(window)
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(time.Until(reset).Seconds()+0.5))))
+				writeLimitError(w, r, http.StatusServiceUnavailable, "bandwidth limit exceeded")
+				return
+			}
+			next.ServeHTTP(&countingWriter{ResponseWriter: w, n: &served}, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// countingWriter adds the bytes written through it to n.
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(p)
+	cw.n.Add(int64(n))
+	return n, err
+}
+
+func (cw *countingWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *countingWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
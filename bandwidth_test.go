@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	const window = 50 * time.Millisecond
+	h := BandwidthLimit(250, window)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+
+	tests := []struct {
+		name       string
+		wait       time.Duration
+		wantStatus int
+	}{
+		{"100 bytes", 0, http.StatusOK},
+		{"200 bytes", 0, http.StatusOK},
+		{"overshoots to 300", 0, http.StatusOK},
+		{"cap reached", 0, http.StatusServiceUnavailable},
+		{"still capped", 0, http.StatusServiceUnavailable},
+		{"next window", window, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/big", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") != "1" {
+				t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
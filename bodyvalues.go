@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+type bodyValuesKey struct{}
+
+// maxBodyValuesBytes bounds the request bodies NormalizeBody parses.
+const maxBodyValuesBytes = 1 << 20
+
+// NormalizeBody parses JSON, URL-encoded and multipart request bodies into
+// one map, available to handlers through BodyValues, so that a route can
+// accept any of the three without caring which it got. A JSON body must be
+// an object. Form fields sent once become strings and repeated ones
+// []any of strings; multipart files are skipped. Bodies that fail to parse
+// get a 400, and those over 1MB a 413. Other content types are passed
+// through untouched.
+//
+// The JSON body is left readable for the handler, and the form fields in
+// r.PostForm.
+func NormalizeBody(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		var values map[string]any
+		switch mediaType {
+		case "application/json":
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyValuesBytes+1))
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "could not read request body")
+				return
+			}
+			if len(body) > maxBodyValuesBytes {
+				writeLimitError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			if err := json.Unmarshal(body, &values); err != nil || values == nil {
+				writeError(w, r, http.StatusBadRequest, "request body must be a JSON object")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+		case "application/x-www-form-urlencoded", "multipart/form-data":
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyValuesBytes)
+			var err error
+			if mediaType == "multipart/form-data" {
+				err = r.ParseMultipartForm(maxBodyValuesBytes)
+				if r.MultipartForm != nil {
+					defer r.MultipartForm.RemoveAll()
+				}
+			} else {
+				err = r.ParseForm()
+			}
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeLimitError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "malformed form body")
+				return
+			}
+			values = formValues(r.PostForm)
+
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), bodyValuesKey{}, values)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// BodyValues returns the request body fields parsed by NormalizeBody, or nil
+// if there were none.
+func BodyValues(ctx context.Context) map[string]any {
+	values, _ := ctx.Value(bodyValuesKey{}).(map[string]any)
+	return values
+}
+
+func formValues(form url.Values) map[string]any {
+	values := make(map[string]any, len(form))
+	for k, vs := range form {
+		if len(vs) == 1 {
+			values[k] = vs[0]
+			continue
+		}
+		list := make([]any, len(vs))
+		for i, v := range vs {
+			list[i] = v
+		}
+		values[k] = list
+	}
+	return values
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBody(t *testing.T) {
+	var multi bytes.Buffer
+	mw := multipart.NewWriter(&multi)
+	mw.WriteField("name", "ann")
+	mw.WriteField("tags", "a")
+	mw.WriteField("tags", "b")
+	fw, _ := mw.CreateFormFile("avatar", "a.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+
+	want := map[string]any{"name": "ann", "tags": []any{"a", "b"}}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		want        map[string]any
+	}{
+		{"JSON", "application/json", `{"name":"ann","tags":["a","b"]}`, http.StatusOK, want},
+		{"JSON with charset", "application/json; charset=utf-8", `{"name":"ann","tags":["a","b"]}`, http.StatusOK, want},
+		{"form", "application/x-www-form-urlencoded", "name=ann&tags=a&tags=b", http.StatusOK, want},
+		{"multipart", mw.FormDataContentType(), multi.String(), http.StatusOK, want},
+		{"malformed JSON", "application/json", `{"name":`, http.StatusBadRequest, nil},
+		{"JSON array", "application/json", `["ann"]`, http.StatusBadRequest, nil},
+		{"JSON null", "application/json", `null`, http.StatusBadRequest, nil},
+		{"malformed form", "application/x-www-form-urlencoded", "name=%zz", http.StatusBadRequest, nil},
+		{"JSON too large", "application/json", `{"a":"` + strings.Repeat("x", maxBodyValuesBytes) + `"}`, http.StatusRequestEntityTooLarge, nil},
+		{"form too large", "application/x-www-form-urlencoded", "a=" + strings.Repeat("x", maxBodyValuesBytes), http.StatusRequestEntityTooLarge, nil},
+		{"other type passed through", "text/plain", "name=ann", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			var rest string
+			h := NormalizeBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = BodyValues(r.Context())
+				b, _ := io.ReadAll(r.Body)
+				rest = string(b)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BodyValues = %#v, want %#v", got, tt.want)
+			}
+			if strings.HasPrefix(tt.contentType, "application/json") && tt.wantStatus == http.StatusOK && rest != tt.body {
+				t.Errorf("handler read %q, want the JSON body intact", rest)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// CircuitBreaker stops sending requests to a failing downstream. After
+// threshold consecutive 5xx responses it opens and answers everything with a
+// 503 for the cooldown period, then lets a single trial request through: if
+// that succeeds the breaker closes again, otherwise it reopens. Use one
+// breaker per downstream dependency.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker returns a closed breaker. name identifies it in logs.
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+// Middleware guards next with the breaker.
+func (cb *CircuitBreaker) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !cb.allow() {
+			retryAfter := max(1, int(cb.cooldown.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeLimitError(w, r, http.StatusServiceUnavailable, "service temporarily unavailable")
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		failed := true
+		defer func() {
+			cb.record(failed)
+		}()
+		next.ServeHTTP(ww, r)
+		failed = ww.Status() >= 500
+	}
+	return http.HandlerFunc(fn)
+}
+
+// allow reports whether a request may go through, moving an open breaker to
+// half-open once the cooldown has passed.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		// This request is the trial; everyone else waits for its outcome.
+		cb.setState(breakerHalfOpen)
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a request. A panicking
+// handler counts as a failure.
+func (cb *CircuitBreaker) record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if !failed {
+		cb.failures = 0
+		if cb.state != breakerClosed {
+			cb.setState(breakerClosed)
+		}
+		return
+	}
+	cb.failures++
+	if cb.state == breakerHalfOpen || cb.failures >= cb.threshold {
+		cb.openedAt = time.Now()
+		if cb.state != breakerOpen {
+			cb.setState(breakerOpen)
+		}
+	}
+}
+
+func (cb *CircuitBreaker) setState(s breakerState) {
+	log.Printf("circuit breaker %q: %s -> %s", cb.name, cb.state, s)
+	cb.state = s
+}
+
+// State returns "closed", "open" or "half-open".
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	cb := NewCircuitBreaker("test", 2, cooldown)
+	fail := true
+	h := cb.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name       string
+		fail       bool
+		wait       time.Duration
+		wantStatus int
+		wantState  string
+	}{
+		{"first failure", true, 0, http.StatusBadGateway, "closed"},
+		{"threshold reached", true, 0, http.StatusBadGateway, "open"},
+		{"short-circuited while open", false, 0, http.StatusServiceUnavailable, "open"},
+		{"failed trial reopens", true, cooldown, http.StatusBadGateway, "open"},
+		{"short-circuited again", false, 0, http.StatusServiceUnavailable, "open"},
+		{"successful trial closes", false, cooldown, http.StatusOK, "closed"},
+		{"closed passes through", false, 0, http.StatusOK, "closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			fail = tt.fail
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := cb.State(); got != tt.wantState {
+				t.Errorf("state = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker("test", 1, time.Millisecond)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := cb.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		close(started)
+		<-release
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	time.Sleep(5 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/trial", nil))
+	}()
+	<-started
+	if got := cb.State(); got != "half-open" {
+		t.Errorf("state during trial = %q, want half-open", got)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status during trial = %d, want 503", rec.Code)
+	}
+	close(release)
+	<-done
+	if got := cb.State(); got != "closed" {
+		t.Errorf("state after trial = %q, want closed", got)
+	}
+}
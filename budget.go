@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+)
+
+// RequestBudget gives each request an overall deadline of d, unless it
+// already has a sooner one. Outbound calls made with HTTPClient and the
+// request's context share that deadline, so a handler that retries an
+// upstream stays within one bounded budget instead of d per attempt.
+func RequestBudget(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RemainingBudget returns how long is left before ctx's deadline, or zero if
+// it has passed. Handlers can consult it before deciding to retry. Without a
+// deadline the budget is unlimited and the maximum duration is returned.
+func RemainingBudget(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return math.MaxInt64
+	}
+	return max(0, time.Until(deadline))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemainingBudget(t *testing.T) {
+	const budget = 200 * time.Millisecond
+	var samples []time.Duration
+	h := RequestBudget(budget)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			samples = append(samples, RemainingBudget(r.Context()))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if samples[0] > budget || samples[0] < budget-50*time.Millisecond {
+		t.Errorf("initial budget = %s, want just under %s", samples[0], budget)
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i] >= samples[i-1] {
+			t.Errorf("budget went from %s to %s, want it to decrease", samples[i-1], samples[i])
+		}
+	}
+}
+
+func TestRemainingBudgetBounds(t *testing.T) {
+	if got := RemainingBudget(context.Background()); got != math.MaxInt64 {
+		t.Errorf("RemainingBudget without a deadline = %s, want the maximum", got)
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	sooner, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		atLeast time.Duration
+		atMost  time.Duration
+	}{
+		{"budget applied", context.Background(), 59 * time.Minute, time.Hour},
+		{"expired", expired, 0, 0},
+		{"sooner deadline kept", sooner, 0, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got time.Duration
+			h := RequestBudget(time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = RemainingBudget(r.Context())
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tt.ctx))
+			if got < tt.atLeast || got > tt.atMost {
+				t.Errorf("RemainingBudget = %s, want between %s and %s", got, tt.atLeast, tt.atMost)
+			}
+		})
+	}
+}
+
+func TestHTTPClientRefusesSpentBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:1/", nil)
+	if _, err := HTTPClient.Do(req); err == nil {
+		t.Error("HTTPClient made a call with no budget left")
+	}
+}
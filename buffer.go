@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// BufferResponse holds back responses of up to maxBytes and sends them with
+// an explicit Content-Length instead of chunked transfer encoding, for
+// clients that cope badly with chunking. Larger responses, and ones the
+// handler flushes, are streamed as usual.
+func BufferResponse(maxBytes int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := newBufferedWriter(w, maxBytes)
+			next.ServeHTTP(bw, r)
+			if bw.Buffered() && bodyAllowed(bw.Status()) {
+				w.Header().Set("Content-Length", strconv.Itoa(len(bw.Body())))
+			}
+			bw.release()
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// bodyAllowed reports whether a response with the given status may have a
+// body.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferResponse(t *testing.T) {
+	tests := []struct {
+		name              string
+		size              int
+		wantContentLength string
+	}{
+		{"small response gets a Content-Length", 100, "100"},
+		{"large response is streamed", 5000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("x", tt.size)
+			h := BufferResponse(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Written in pieces, as a handler streaming output would.
+				for i := 0; i < len(body); i += 10 {
+					w.Write([]byte(body[i : i+10]))
+				}
+			}))
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := resp.Header.Get("Content-Length"); got != tt.wantContentLength {
+				t.Errorf("Content-Length = %q, want %q", got, tt.wantContentLength)
+			}
+			chunked := len(resp.TransferEncoding) > 0 && resp.TransferEncoding[0] == "chunked"
+			if chunked != (tt.wantContentLength == "") {
+				t.Errorf("Transfer-Encoding = %v", resp.TransferEncoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// appVersion describes the running build: the module version when built
+// from a tagged release, otherwise the VCS revision Go stamped into the
+// binary, suffixed with "-dirty" for uncommitted changes.
+func appVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// BuildInfoHeaders tells which build and which instance served a response,
+// with X-App-Version and X-Served-By, and how long it took to start
+// answering, with X-Request-Duration in milliseconds.
+func BuildInfoHeaders(next http.Handler) http.Handler {
+	version := appVersion()
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-App-Version", version)
+		w.Header().Set("X-Served-By", host)
+		dw := &durationWriter{ResponseWriter: w, start: time.Now()}
+		next.ServeHTTP(dw, r)
+		// A handler that wrote nothing has its 200 sent once it returns.
+		if !dw.wroteHeader {
+			dw.setDuration()
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// durationWriter sets X-Request-Duration just before the response headers
+// are sent.
+type durationWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (dw *durationWriter) WriteHeader(code int) {
+	if !dw.wroteHeader {
+		dw.wroteHeader = true
+		dw.setDuration()
+	}
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+func (dw *durationWriter) setDuration() {
+	ms := float64(time.Since(dw.start)) / float64(time.Millisecond)
+	dw.Header().Set("X-Request-Duration", strconv.FormatFloat(ms, 'f', 3, 64))
+}
+
+func (dw *durationWriter) Write(p []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	return dw.ResponseWriter.Write(p)
+}
+
+func (dw *durationWriter) Flush() {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := dw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (dw *durationWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBuildInfoHeaders(t *testing.T) {
+	host, _ := os.Hostname()
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		minMs   float64
+	}{
+		{"body", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("ok"))
+		}, 20},
+		{"status only", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, 0},
+		{"flushed", func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+			w.Write([]byte("streamed"))
+		}, 0},
+		{"empty response", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+		}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(BuildInfoHeaders(tt.handler))
+			defer srv.Close()
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if got := resp.Header.Get("X-App-Version"); got == "" {
+				t.Error("no X-App-Version")
+			}
+			if got := resp.Header.Get("X-Served-By"); got != host {
+				t.Errorf("X-Served-By = %q, want %q", got, host)
+			}
+			ms, err := strconv.ParseFloat(resp.Header.Get("X-Request-Duration"), 64)
+			if err != nil || ms < tt.minMs || ms > 5000 {
+				t.Errorf("X-Request-Duration = %q, want at least %vms", resp.Header.Get("X-Request-Duration"), tt.minMs)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetCacheControl sets a Cache-Control header letting caches reuse the
+// response for maxAge, and then serve it stale for up to
+// staleWhileRevalidate more while they fetch a fresh copy in the background.
+// Durations are rounded down to whole seconds.
+func SetCacheControl(w http.ResponseWriter, maxAge, staleWhileRevalidate time.Duration) {
+	v := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+	if staleWhileRevalidate > 0 {
+		v += fmt.Sprintf(", stale-while-revalidate=%d", int64(staleWhileRevalidate/time.Second))
+	}
+	w.Header().Set("Cache-Control", v)
+}
+
+// staleWhileRevalidate returns the stale-while-revalidate directive of a
+// Cache-Control header, if it has one.
+func staleWhileRevalidate(cc string) (time.Duration, bool) {
+	for _, d := range strings.Split(cc, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(d), "stale-while-revalidate="); ok {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				return time.Duration(n) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// CacheOptions configures a ResponseCache.
+type CacheOptions struct {
+	// TTL is how long a cached response is served without calling the
+	// handler.
+	TTL time.Duration
+
+	// MaxBodyBytes caps the size of a cacheable response. Defaults to 1MB.
+	MaxBodyBytes int
+
+	// MaxEntries caps the number of cached URLs. Defaults to 1000.
+	MaxEntries int
+
+	// ServeStaleOnError serves the last good response, however old, with a
+	// "Warning: 110" header when the handler fails with a 5xx.
+	ServeStaleOnError bool
+
+	// StaleWhileRevalidate is how long after TTL an expired response is
+	// still served, straight away, while the handler refreshes it in the
+	// background. A stale-while-revalidate directive in the response's
+	// Cache-Control header, as set by SetCacheControl, overrides it.
+	StaleWhileRevalidate time.Duration
+}
+
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+
+	// staleUntil is when the response stops being served while it is
+	// revalidated; refreshing is set while that is under way. Both are
+	// guarded by the cache's mutex.
+	staleUntil time.Time
+	refreshing bool
+}
+
+// ResponseCache caches successful GET responses in memory, keyed by URL.
+type ResponseCache struct {
+	opts    CacheOptions
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+// NewResponseCache returns an empty ResponseCache.
+func NewResponseCache(opts CacheOptions) *ResponseCache {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+	return &ResponseCache{opts: opts, entries: make(map[string]*cachedResponse)}
+}
+
+// Middleware serves fresh responses from the cache and stores successful
+// responses from next. Only headers set by next are stored, and responses
+// that set a cookie or a Vary header are never stored, nor are empty
+// responses from a handler that gave up because its client went away.
+func (c *ResponseCache) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !cacheableRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.URL.String()
+
+		now := time.Now()
+		c.mu.Lock()
+		cached := c.entries[key]
+		revalidate := cached != nil && !now.Before(cached.expires) && now.Before(cached.staleUntil)
+		refresh := revalidate && !cached.refreshing
+		if refresh {
+			cached.refreshing = true
+		}
+		c.mu.Unlock()
+
+		if cached != nil && now.Before(cached.expires) {
+			cached.writeTo(w)
+			return
+		}
+		if revalidate {
+			if refresh {
+				go c.refresh(next, detachedRequest(r), key, cached)
+			}
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			cached.writeTo(w)
+			return
+		}
+
+		header := w.Header().Clone()
+		bw := newBufferedWriter(w, c.opts.MaxBodyBytes)
+		next.ServeHTTP(bw, r)
+		if !bw.Buffered() {
+			return
+		}
+
+		status := bw.Status()
+		switch {
+		case status == http.StatusOK && bw.Wrote() && r.Context().Err() == nil:
+			// Only the handler's own headers are stored: those set by
+			// middleware outside the cache, such as a CSRF cookie, belong
+			// to this request. Responses setting cookies aren't cached,
+			// and neither are those that vary on request headers, since
+			// the key is the URL alone.
+			if stored, ok := handlerHeader(header, w.Header()); ok && stored.Get("Vary") == "" {
+				c.store(key, c.newEntry(stored, bw.Body()))
+			}
+		case status >= 500 && cached != nil && c.opts.ServeStaleOnError:
+			// Drop whatever the failed handler set and replay the last good
+			// response over the headers we started with.
+			for k := range w.Header() {
+				delete(w.Header(), k)
+			}
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			cached.writeTo(w)
+			return
+		}
+		bw.release()
+	}
+	return http.HandlerFunc(fn)
+}
+
+// newEntry builds a cache entry for a 200 response with the given header
+// and body, both of which it copies.
+func (c *ResponseCache) newEntry(header http.Header, body []byte) *cachedResponse {
+	stored := header.Clone()
+	if stored.Get("Content-Type") == "" {
+		stored.Set("Content-Type", http.DetectContentType(body))
+	}
+	swr := c.opts.StaleWhileRevalidate
+	if d, ok := staleWhileRevalidate(stored.Get("Cache-Control")); ok {
+		swr = d
+	}
+	expires := time.Now().Add(c.opts.TTL)
+	return &cachedResponse{
+		status:     http.StatusOK,
+		header:     stored,
+		body:       append([]byte(nil), body...),
+		expires:    expires,
+		staleUntil: expires.Add(swr),
+	}
+}
+
+// refresh re-runs next for a stale entry in the background, with r detached
+// from the client's request, and replaces the entry if it succeeds.
+func (c *ResponseCache) refresh(next http.Handler, r *http.Request, key string, stale *cachedResponse) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("cache: refreshing %s panicked: %v", key, p)
+		}
+		c.mu.Lock()
+		stale.refreshing = false
+		c.mu.Unlock()
+	}()
+
+	rw := &refreshWriter{header: make(http.Header)}
+	next.ServeHTTP(rw, r)
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if rw.status != http.StatusOK {
+		log.Printf("cache: refreshing %s got status %d, keeping stale response", key, rw.status)
+		return
+	}
+	if len(rw.body) > c.opts.MaxBodyBytes {
+		return
+	}
+	if _, ok := rw.header["Set-Cookie"]; ok {
+		log.Printf("cache: refreshing %s set a cookie, keeping stale response", key)
+		return
+	}
+	if rw.header.Get("Vary") != "" {
+		log.Printf("cache: refreshing %s got a response varying on %s, keeping stale response", key, rw.header.Get("Vary"))
+		return
+	}
+	c.store(key, c.newEntry(rw.header, rw.body))
+}
+
+// detachedRequest returns a copy of r for work that outlives it: its context
+// isn't cancelled along with r's, and it has its own chi routing context,
+// since chi puts r's back in a pool for reuse once r has been served.
+func detachedRequest(r *http.Request) *http.Request {
+	ctx := context.WithoutCancel(r.Context())
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		fresh := chi.NewRouteContext()
+		fresh.Routes = rctx.Routes
+		fresh.RoutePath = rctx.RoutePath
+		fresh.RouteMethod = rctx.RouteMethod
+		fresh.RoutePatterns = slices.Clone(rctx.RoutePatterns)
+		fresh.URLParams.Keys = slices.Clone(rctx.URLParams.Keys)
+		fresh.URLParams.Values = slices.Clone(rctx.URLParams.Values)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, fresh)
+	}
+	return r.Clone(ctx)
+}
+
+// refreshWriter collects a background refresh's response in memory.
+type refreshWriter struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+func (rw *refreshWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *refreshWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+}
+
+func (rw *refreshWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body = append(rw.body, p...)
+	return len(p), nil
+}
+
+func (c *ResponseCache) store(key string, resp *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.opts.MaxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = resp
+}
+
+func (cr *cachedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range cr.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(cr.status)
+	w.Write(cr.body)
+}
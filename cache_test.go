@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestResponseCacheServeStaleOnError(t *testing.T) {
+	fail := false
+	c := NewResponseCache(CacheOptions{TTL: time.Nanosecond, ServeStaleOnError: true})
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("boom"))
+			return
+		}
+		w.Write([]byte("good"))
+	}))
+
+	tests := []struct {
+		name        string
+		fail        bool
+		wantStatus  int
+		wantBody    string
+		wantWarning bool
+	}{
+		{"success is cached", false, http.StatusOK, "good", false},
+		{"error falls back to the cached body", true, http.StatusOK, "good", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail = tt.fail
+			time.Sleep(time.Millisecond) // let the entry expire
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+			if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+			if got := rec.Header().Get("Warning") != ""; got != tt.wantWarning {
+				t.Errorf("Warning = %q", rec.Header().Get("Warning"))
+			}
+		})
+	}
+}
+
+func TestResponseCacheKeepsCookiesPerClient(t *testing.T) {
+	calls := 0
+	c := NewResponseCache(CacheOptions{TTL: time.Minute})
+	cached := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("page"))
+	}))
+	h := CSRF(cached)
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page", nil))
+		if rec.Body.String() != "page" || rec.Header().Get("Content-Type") != "text/plain" {
+			t.Fatalf("response %d = %q %v", i, rec.Body, rec.Header())
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("response %d has cookies %v, want one CSRF cookie", i, cookies)
+		}
+		tokens = append(tokens, cookies[0].Value)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if tokens[0] == tokens[1] {
+		t.Error("two clients got the same CSRF cookie from the cache")
+	}
+}
+
+func TestResponseCacheSkipsResponsesSettingCookies(t *testing.T) {
+	calls := 0
+	c := NewResponseCache(CacheOptions{TTL: time.Minute})
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Write([]byte("hi"))
+	}))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/me", nil))
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		version   int
+		refreshed = make(chan string, 1)
+	)
+	c := NewResponseCache(CacheOptions{TTL: 20 * time.Millisecond})
+	r := chi.NewRouter()
+	r.With(c.Middleware).Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		version++
+		v := version
+		mu.Unlock()
+		if v > 1 {
+			// Give chi time to hand the routing context to other requests.
+			time.Sleep(20 * time.Millisecond)
+		}
+		id := chi.URLParam(r, "id")
+		SetCacheControl(w, 20*time.Millisecond, time.Minute)
+		fmt.Fprintf(w, "%s v%d", id, v)
+		if v > 1 {
+			refreshed <- id
+		}
+	})
+	r.Get("/other/{name}", okHandler.ServeHTTP)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		wait        time.Duration
+		wantBody    string
+		wantWarning bool
+	}{
+		{"first request fills the cache", 0, "42 v1", false},
+		{"fresh hit", 0, "42 v1", false},
+		{"stale served while refreshing", 30 * time.Millisecond, "42 v1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			rec := get("/items/42")
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("Warning") != ""; got != tt.wantWarning {
+				t.Errorf("Warning = %q, want present %v", rec.Header().Get("Warning"), tt.wantWarning)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "max-age=0, stale-while-revalidate=60" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+		})
+	}
+
+	// Requests served while the refresh runs reuse chi's pooled contexts.
+	for i := 0; i < 20; i++ {
+		get(fmt.Sprintf("/other/n%d", i))
+	}
+	select {
+	case id := <-refreshed:
+		if id != "42" {
+			t.Errorf("refresh saw id %q, want 42", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stale entry was not refreshed")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if rec := get("/items/42"); rec.Body.String() != "42 v2" {
+		t.Errorf("after refresh body = %q, want %q", rec.Body.String(), "42 v2")
+	}
+}
+
+func TestResponseCacheSkipsUnusableResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request) error
+		cancel  bool
+	}{
+		{"client went away", func(w http.ResponseWriter, r *http.Request) error {
+			<-r.Context().Done()
+			return r.Context().Err()
+		}, true},
+		{"nothing written", func(w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}, false},
+		{"varies on a request header", func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Vary", "Accept-Language")
+			w.Write([]byte(r.Header.Get("Accept-Language")))
+			return nil
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := NewResponseCache(CacheOptions{TTL: time.Minute})
+			h := c.Middleware(Handler(func(w http.ResponseWriter, r *http.Request) error {
+				calls++
+				if calls > 1 {
+					w.Write([]byte("fresh"))
+					return nil
+				}
+				return tt.handler(w, r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			if tt.cancel {
+				ctx, cancel := context.WithCancel(req.Context())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				req = req.WithContext(ctx)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+			if calls != 2 {
+				t.Errorf("handler ran %d times, want 2", calls)
+			}
+			if rec.Code != http.StatusOK || rec.Body.String() != "fresh" {
+				t.Errorf("second response = %d %q, want 200 %q", rec.Code, rec.Body.String(), "fresh")
+			}
+		})
+	}
+}
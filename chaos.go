@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Chaos makes requests misbehave, to exercise clients' timeout and retry
+// handling: every request is delayed by a random duration of up to latency,
+// and then, with probability errorRate, answered with a synthetic 500
+// instead of reaching the handler. It does nothing at all unless the
+// CHAOS_ENABLED environment variable is true when it is set up.
+func Chaos(latency time.Duration, errorRate float64) func(next http.Handler) http.Handler {
+	enabled := envFlag("CHAOS_ENABLED")
+	if enabled {
+		log.Printf("chaos enabled: up to %s added latency, %.0f%% errors", latency, errorRate*100)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if latency > 0 {
+				t := time.NewTimer(time.Duration(rand.Int63n(int64(latency) + 1)))
+				select {
+				case <-t.C:
+				case <-r.Context().Done():
+					t.Stop()
+					return
+				}
+			}
+			if rand.Float64() < errorRate {
+				writeError(w, r, http.StatusInternalServerError, "chaos: injected failure")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
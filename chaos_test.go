@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChaos(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name       string
+		enabled    string
+		latency    time.Duration
+		errorRate  float64
+		cancel     bool
+		wantStatus int
+		wantCalled bool
+	}{
+		{"always fails", "true", 0, 1, false, http.StatusInternalServerError, false},
+		{"never fails", "true", 0, 0, false, http.StatusOK, true},
+		{"delayed", "true", 5 * time.Millisecond, 0, false, http.StatusOK, true},
+		{"disabled", "", 0, 1, false, http.StatusOK, true},
+		{"disabled explicitly", "false", time.Hour, 1, false, http.StatusOK, true},
+		{"client gives up during delay", "true", time.Hour, 0, true, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAOS_ENABLED", tt.enabled)
+			var called bool
+			h := Chaos(tt.latency, tt.errorRate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			for i := 0; i < 20; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if tt.cancel {
+					ctx, cancel := context.WithTimeout(req.Context(), 10*time.Millisecond)
+					defer cancel()
+					req = req.WithContext(ctx)
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus)
+				}
+				if called != tt.wantCalled {
+					t.Fatalf("request %d: handler called = %v, want %v", i, called, tt.wantCalled)
+				}
+				if tt.cancel {
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// clfTimeFormat is the timestamp format of the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// CLFLogger writes one access log line per request to out in Apache's Common
+// Log Format, or in Combined Log Format, which adds the referer and user
+// agent, when combined is true:
+//
+//	203.0.113.7 - - [10/Oct/2023:13:55:36 +0000] "GET /x HTTP/1.1" 200 123
+//
+// The client host is resolved through TrustedProxies.
+func CLFLogger(out io.Writer, combined bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			defer func() {
+				io.WriteString(out, clfLine(r, start, ww.Status(), ww.BytesWritten(), combined))
+			}()
+			next.ServeHTTP(ww, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func clfLine(r *http.Request, t time.Time, status, size int, combined bool) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.Itoa(size)
+	}
+	line := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s",
+		clientIP(r), t.Format(clfTimeFormat),
+		r.Method, clfEscape(r.RequestURI), r.Proto, status, bytes)
+	if combined {
+		line += fmt.Sprintf(" \"%s\" \"%s\"", clfField(r.Referer()), clfField(r.UserAgent()))
+	}
+	return line + "\n"
+}
+
+// clfField returns v escaped for a quoted log field, or "-" if it is empty.
+func clfField(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return clfEscape(v)
+}
+
+// clfEscape escapes quotes, backslashes and control characters, so that
+// client-supplied values can't break up or forge log lines.
+func clfEscape(v string) string {
+	var b strings.Builder
+	for _, c := range []byte(v) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
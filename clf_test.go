@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCLFLogger(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	oldProxies := TrustedProxies
+	TrustedProxies = []*net.IPNet{proxies}
+	t.Cleanup(func() { TrustedProxies = oldProxies })
+
+	tests := []struct {
+		name       string
+		combined   bool
+		remoteAddr string
+		target     string
+		header     http.Header
+		status     int
+		body       string
+		want       string
+	}{
+		{
+			name:       "common",
+			remoteAddr: "203.0.113.7:5000",
+			target:     "/x?q=1",
+			body:       "hello world",
+			want:       `203.0.113.7 - - [DATE] "GET /x?q=1 HTTP/1.1" 200 11`,
+		},
+		{
+			name:       "empty body",
+			remoteAddr: "203.0.113.7:5000",
+			target:     "/gone",
+			status:     http.StatusNoContent,
+			want:       `203.0.113.7 - - [DATE] "GET /gone HTTP/1.1" 204 -`,
+		},
+		{
+			name:       "client behind trusted proxy",
+			remoteAddr: "10.0.0.1:5000",
+			target:     "/x",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.2"}},
+			body:       "ok",
+			want:       `198.51.100.2 - - [DATE] "GET /x HTTP/1.1" 200 2`,
+		},
+		{
+			name:       "spoofed forwarding ignored",
+			remoteAddr: "203.0.113.7:5000",
+			target:     "/x",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.2"}},
+			body:       "ok",
+			want:       `203.0.113.7 - - [DATE] "GET /x HTTP/1.1" 200 2`,
+		},
+		{
+			name:       "combined",
+			combined:   true,
+			remoteAddr: "203.0.113.7:5000",
+			target:     "/x",
+			header:     http.Header{"Referer": {"https://example.com/"}, "User-Agent": {`curl/8.0 "quoted"`}},
+			body:       "ok",
+			want:       `203.0.113.7 - - [DATE] "GET /x HTTP/1.1" 200 2 "https://example.com/" "curl/8.0 \"quoted\""`,
+		},
+		{
+			name:       "combined without referer",
+			combined:   true,
+			remoteAddr: "203.0.113.7:5000",
+			target:     "/x",
+			header:     http.Header{"User-Agent": {"agent\nforged line"}},
+			body:       "ok",
+			want:       `203.0.113.7 - - [DATE] "GET /x HTTP/1.1" 200 2 "-" "agent\x0aforged line"`,
+		},
+	}
+	dateRE := regexp.MustCompile(`\[([^]]+)\]`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			h := CLFLogger(&out, tt.combined)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			before := time.Now().Truncate(time.Second)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			line := out.String()
+			m := dateRE.FindStringSubmatch(line)
+			if m == nil {
+				t.Fatalf("no timestamp in %q", line)
+			}
+			if ts, err := time.Parse(clfTimeFormat, m[1]); err != nil || ts.Before(before) || ts.After(time.Now()) {
+				t.Errorf("timestamp %q is not the request time (%v)", m[1], err)
+			}
+			if got := dateRE.ReplaceAllString(line, "[DATE]"); got != tt.want+"\n" {
+				t.Errorf("line = %q\nwant   %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
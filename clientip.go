@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies lists the networks whose X-Forwarded-For header we believe.
+// Requests from anywhere else are attributed to their direct peer address.
+var TrustedProxies []*net.IPNet
+
+// clientIP resolves the address of the client behind r. The rightmost
+// X-Forwarded-For entry that is not itself a trusted proxy wins, so clients
+// cannot spoof their address by prepending entries.
+func clientIP(r *http.Request) string {
+	ip := stripPort(r.RemoteAddr)
+	if !trustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range TrustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestScheme resolves the scheme the client used to reach us: https for
+// TLS connections, otherwise whatever a trusted proxy reports in
+// X-Forwarded-Proto, defaulting to http.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if trustedProxy(stripPort(r.RemoteAddr)) {
+		if proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto == "https" || proto == "http" {
+			return proto
+		}
+	}
+	return "http"
+}
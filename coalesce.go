@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// maxCoalescedBytes caps the response Coalesce holds to share. Larger ones
+// go straight to the first client and aren't shared.
+const maxCoalescedBytes = 1 << 20
+
+// coalescedCall is one in-flight handler execution that identical requests
+// wait on and then share.
+type coalescedCall struct {
+	done   chan struct{}
+	ok     bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Coalesce collapses concurrent identical GET requests into one handler
+// execution, singleflight style: the first request runs the handler and the
+// rest wait for it and get a copy of its response. Requests are keyed by
+// method and URL, so only cacheable, body-less requests that carry no
+// credentials are coalesced.
+func Coalesce(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	calls := make(map[string]*coalescedCall)
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !cacheableRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.Method + " " + r.URL.String()
+
+		mu.Lock()
+		if c, ok := calls[key]; ok {
+			mu.Unlock()
+			select {
+			case <-c.done:
+			case <-r.Context().Done():
+				return
+			}
+			if !c.ok {
+				// The first request panicked, streamed or wrote nothing,
+				// set a cookie, or had a response too large to hold, so
+				// there is nothing to replay.
+				next.ServeHTTP(w, r)
+				return
+			}
+			for k, v := range c.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(c.status)
+			w.Write(c.body)
+			return
+		}
+		c := &coalescedCall{done: make(chan struct{})}
+		calls[key] = c
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			delete(calls, key)
+			mu.Unlock()
+			close(c.done)
+		}()
+
+		before := w.Header().Clone()
+		bw := newBufferedWriter(w, maxCoalescedBytes)
+		next.ServeHTTP(bw, r)
+		// A handler that wrote nothing, typically because the first
+		// client went away, leaves nothing worth sharing.
+		finished := bw.Wrote() && r.Context().Err() == nil
+		if header, shareable := handlerHeader(before, w.Header()); finished && bw.Buffered() && shareable {
+			c.ok = true
+			c.status = bw.Status()
+			c.header = header
+			c.body = append([]byte(nil), bw.Body()...)
+		}
+		bw.release()
+	}
+	return http.HandlerFunc(fn)
+}
+
+// handlerHeader returns the headers in after that a handler added or
+// changed, given the headers middleware outside it had already set in
+// before. Those belong to the request they were set for, e.g. its request
+// ID or CSRF cookie, and must not be replayed to other clients. shareable
+// is false if the handler itself set a cookie, since the whole response is
+// then meant for one client.
+func handlerHeader(before, after http.Header) (header http.Header, shareable bool) {
+	header = make(http.Header)
+	for k, v := range after {
+		if !slices.Equal(before[k], v) {
+			header[k] = slices.Clone(v)
+		}
+	}
+	if _, ok := header["Set-Cookie"]; ok {
+		return nil, false
+	}
+	return header, true
+}
+
+// cacheableRequest reports whether r's response can be assumed to depend only
+// on its method and URL, so that it may be shared with other clients.
+func cacheableRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.ContentLength == 0 &&
+		r.Header.Get("Authorization") == "" &&
+		r.Header.Get("Cookie") == ""
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// coalesceRun sends n identical concurrent GETs through h, holding the
+// first handler call until the rest are waiting on it.
+func coalesceRun(t *testing.T, n int, handler func(w http.ResponseWriter, r *http.Request)) (calls int64, recs []*httptest.ResponseRecorder) {
+	t.Helper()
+	var count atomic.Int64
+	release := make(chan struct{})
+	var seq atomic.Int64
+	coalesced := Coalesce(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) == 1 {
+			<-release
+		}
+		handler(w, r)
+	}))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stands in for middleware outside Coalesce setting per-request
+		// headers.
+		id := strconv.FormatInt(seq.Add(1), 10)
+		w.Header().Set("X-Request-Id", id)
+		http.SetCookie(w, &http.Cookie{Name: "outer", Value: id})
+		coalesced.ServeHTTP(w, r)
+	})
+
+	recs = make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		recs[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items?id=1", nil))
+		}(recs[i])
+		if i == 0 {
+			for count.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return count.Load(), recs
+}
+
+func TestCoalesce(t *testing.T) {
+	calls, recs := coalesceRun(t, 5, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	})
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	ids := make(map[string]bool)
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"id":1}` || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("response %d = %d %q %v", i, rec.Code, rec.Body, rec.Header())
+		}
+		// Every client keeps the headers and cookies set for its own
+		// request.
+		id := rec.Header().Get("X-Request-Id")
+		if ids[id] {
+			t.Errorf("response %d got another client's X-Request-Id %s", i, id)
+		}
+		ids[id] = true
+		if ids := rec.Header().Values("X-Request-Id"); len(ids) != 1 {
+			t.Errorf("response %d has X-Request-Id %v, want its own only", i, ids)
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Value != id {
+			t.Errorf("response %d has cookies %v, want only outer=%s", i, cookies, id)
+		}
+	}
+}
+
+func TestCoalesceDoesNotShareCookies(t *testing.T) {
+	var n atomic.Int64
+	calls, recs := coalesceRun(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: strconv.FormatInt(n.Add(1), 10)})
+		w.Write([]byte("hi"))
+	})
+	if calls != 3 {
+		t.Errorf("handler ran %d times, want once per request since it sets a cookie", calls)
+	}
+	seen := make(map[string]bool)
+	for _, rec := range recs {
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == "session" {
+				if seen[c.Value] {
+					t.Errorf("session cookie %s sent to two clients", c.Value)
+				}
+				seen[c.Value] = true
+			}
+		}
+	}
+}
+
+func TestCoalesceDoesNotShareUnfinishedResponses(t *testing.T) {
+	large := strings.Repeat("x", maxCoalescedBytes+1)
+	tests := []struct {
+		name   string
+		leader func(w http.ResponseWriter, r *http.Request) error
+		cancel bool
+	}{
+		{"first client went away", func(w http.ResponseWriter, r *http.Request) error {
+			<-r.Context().Done()
+			return r.Context().Err()
+		}, true},
+		{"response too large to hold", func(w http.ResponseWriter, r *http.Request) error {
+			w.Write([]byte(large))
+			return nil
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			started := make(chan struct{})
+			release := make(chan struct{})
+			h := Coalesce(Handler(func(w http.ResponseWriter, r *http.Request) error {
+				if calls.Add(1) == 1 {
+					close(started)
+					<-release
+					return tt.leader(w, r)
+				}
+				w.Write([]byte("fresh"))
+				return nil
+			}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			leaderDone := make(chan struct{})
+			go func() {
+				defer close(leaderDone)
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil).WithContext(ctx))
+			}()
+			<-started
+
+			recs := make([]*httptest.ResponseRecorder, 3)
+			var wg sync.WaitGroup
+			for i := range recs {
+				recs[i] = httptest.NewRecorder()
+				wg.Add(1)
+				go func(rec *httptest.ResponseRecorder) {
+					defer wg.Done()
+					h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+				}(recs[i])
+			}
+			time.Sleep(50 * time.Millisecond)
+			if tt.cancel {
+				cancel()
+			}
+			close(release)
+			<-leaderDone
+			wg.Wait()
+
+			if n := calls.Load(); n != 4 {
+				t.Errorf("handler ran %d times, want once per request", n)
+			}
+			for i, rec := range recs {
+				if rec.Code != http.StatusOK || rec.Body.String() != "fresh" {
+					t.Errorf("follower %d got %d %.20q, want 200 %q", i, rec.Code, rec.Body.String(), "fresh")
+				}
+			}
+		})
+	}
+}
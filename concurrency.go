@@ -0,0 +1,104 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// PerClientConcurrency caps how many requests a single client IP may have in
+// flight at once, rejecting the excess with a 429 so that one client with
+// slow requests cannot tie up every worker.
+func PerClientConcurrency(max int) func(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	inflight := make(map[string]int)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+
+			mu.Lock()
+			if inflight[ip] >= max {
+				mu.Unlock()
+				writeLimitError(w, r, http.StatusTooManyRequests, "too many concurrent requests")
+				return
+			}
+			inflight[ip]++
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				if inflight[ip]--; inflight[ip] == 0 {
+					delete(inflight, ip)
+				}
+				mu.Unlock()
+			}()
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RouteThrottle caps the requests in flight on the routes it is mounted on at
+// limit. Up to queueLen more wait for a slot and are admitted in the order
+// they arrived; beyond that, requests are rejected with a 503. A queued
+// request whose client goes away leaves the queue.
+func RouteThrottle(limit, queueLen int) func(next http.Handler) http.Handler {
+	var (
+		mu       sync.Mutex
+		inflight int
+		queue    = list.New() // of chan struct{}, closed when admitted
+	)
+
+	release := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if front := queue.Front(); front != nil {
+			// Hand the slot straight to the longest waiter, so a newcomer
+			// can't take it first.
+			close(queue.Remove(front).(chan struct{}))
+			return
+		}
+		inflight--
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			if inflight < limit && queue.Len() == 0 {
+				inflight++
+				mu.Unlock()
+			} else {
+				if queue.Len() >= queueLen {
+					mu.Unlock()
+					writeLimitError(w, r, http.StatusServiceUnavailable, "server busy")
+					return
+				}
+				admitted := make(chan struct{})
+				elem := queue.PushBack(admitted)
+				mu.Unlock()
+
+				select {
+				case <-admitted:
+				case <-r.Context().Done():
+					mu.Lock()
+					select {
+					case <-admitted:
+						// Admitted just as the client left; pass the slot on.
+						mu.Unlock()
+						release()
+					default:
+						queue.Remove(elem)
+						mu.Unlock()
+					}
+					return
+				}
+			}
+			defer release()
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPerClientConcurrency(t *testing.T) {
+	var inflight atomic.Int64
+	release := make(chan struct{})
+	h := PerClientConcurrency(2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight.Add(1)
+		<-release
+	}))
+
+	serve := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Two slow requests from one IP use up its allowance.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve("10.0.0.1")
+		}()
+	}
+	for inflight.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if rec := serve("10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("third request from the same IP: status = %d, want 429", rec.Code)
+	}
+	otherDone := make(chan int)
+	go func() { otherDone <- serve("10.0.0.2").Code }()
+	for inflight.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+	if code := <-otherDone; code != http.StatusOK {
+		t.Errorf("request from another IP: status = %d, want 200", code)
+	}
+	if rec := serve("10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("request after the others finished: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRouteThrottle(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		order    []string
+		inflight atomic.Int64
+		peak     atomic.Int64
+	)
+	block := make(chan struct{})
+	h := RouteThrottle(1, 3)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := inflight.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		defer inflight.Add(-1)
+		id := r.URL.Query().Get("id")
+		mu.Lock()
+		order = append(order, id)
+		mu.Unlock()
+		if id == "first" {
+			<-block
+		}
+	}))
+	serve := func(req *http.Request, codes chan<- int) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		codes <- rec.Code
+	}
+	// waitQueued gives a request that was just started time to join the
+	// queue, since the queue itself can't be observed.
+	waitQueued := func() { time.Sleep(20 * time.Millisecond) }
+
+	codes := make(chan int, 10)
+	go serve(httptest.NewRequest(http.MethodGet, "/?id=first", nil), codes)
+	for inflight.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A queued request whose client leaves gives up its place.
+	ctx, cancel := context.WithCancel(context.Background())
+	go serve(httptest.NewRequest(http.MethodGet, "/?id=gone", nil).WithContext(ctx), codes)
+	waitQueued()
+	cancel()
+	<-codes
+
+	for _, id := range []string{"q1", "q2", "q3"} {
+		go serve(httptest.NewRequest(http.MethodGet, "/?id="+id, nil), codes)
+		waitQueued()
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?id=rejected", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("request beyond the queue: status = %d, want 503", rec.Code)
+	}
+
+	close(block)
+	for i := 0; i < 4; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("admitted request: status = %d, want 200", code)
+		}
+	}
+	if want := []string{"first", "q1", "q2", "q3"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("served in order %v, want %v", order, want)
+	}
+	if p := peak.Load(); p != 1 {
+		t.Errorf("%d requests in flight at once, want at most 1", p)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config is the configuration the server was started with, read from the
+// environment by LoadConfig.
+type Config struct {
+	Addr            string        `json:"addr"`
+	Debug           bool          `json:"debug"`
+	LogFormat       string        `json:"log_format"`
+	TraceFile       string        `json:"trace_file"`
+	Warmup          time.Duration `json:"warmup"`
+	ProxyUpstream   string        `json:"proxy_upstream"`
+	DrainDelay      time.Duration `json:"drain_delay"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	RequestTimeout  time.Duration `json:"request_timeout"`
+	ChaosLatency    time.Duration `json:"chaos_latency"`
+	ChaosErrorRate  float64       `json:"chaos_error_rate"`
+	TraceSampleRate float64       `json:"trace_sample_rate"`
+
+	// Secrets. Redacted masks them.
+	AdminToken   string `json:"admin_token"`
+	CursorSecret string `json:"cursor_secret"`
+}
+
+// LoadConfig reads the configuration from the environment. Values that fail
+// to parse keep their defaults.
+func LoadConfig() Config {
+	cfg := Config{
+		Addr:            ":3333",
+		Debug:           envFlag("DEBUG"),
+		LogFormat:       os.Getenv("LOG_FORMAT"),
+		TraceFile:       os.Getenv("TRACE_FILE"),
+		ProxyUpstream:   os.Getenv("PROXY_UPSTREAM"),
+		DrainDelay:      DrainDelay,
+		ShutdownTimeout: ShutdownTimeout,
+		RequestTimeout:  60 * time.Second,
+		AdminToken:      AdminToken,
+		CursorSecret:    os.Getenv("CURSOR_SECRET"),
+	}
+	cfg.Warmup, _ = time.ParseDuration(os.Getenv("WARMUP"))
+	if d, err := time.ParseDuration(os.Getenv("DRAIN_DELAY")); err == nil {
+		cfg.DrainDelay = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil {
+		cfg.ShutdownTimeout = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("REQUEST_TIMEOUT")); err == nil {
+		cfg.RequestTimeout = d
+	}
+	cfg.ChaosLatency, _ = time.ParseDuration(os.Getenv("CHAOS_LATENCY"))
+	cfg.ChaosErrorRate, _ = strconv.ParseFloat(os.Getenv("CHAOS_ERROR_RATE"), 64)
+	cfg.TraceSampleRate, _ = strconv.ParseFloat(os.Getenv("TRACE_SAMPLE_RATE"), 64)
+	return cfg
+}
+
+// Redacted returns a copy of cfg that is safe to show: secrets are replaced
+// with a mask, which still tells whether they were set.
+func (cfg Config) Redacted() Config {
+	cfg.AdminToken = redact(cfg.AdminToken)
+	cfg.CursorSecret = redact(cfg.CursorSecret)
+	return cfg
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "********"
+}
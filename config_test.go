@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestConfigRedacted(t *testing.T) {
+	oldToken := AdminToken
+	AdminToken = "admin-secret"
+	t.Cleanup(func() { AdminToken = oldToken })
+
+	cfg := Config{
+		Addr:          ":8080",
+		ProxyUpstream: "http://upstream:9000",
+		AdminToken:    "admin-secret",
+		CursorSecret:  "cursor-secret",
+	}
+	tests := []struct {
+		name       string
+		cfg        Config
+		token      string
+		wantStatus int
+		want       map[string]string
+	}{
+		{"secrets masked", cfg, "admin-secret", http.StatusOK, map[string]string{
+			"addr":           ":8080",
+			"proxy_upstream": "http://upstream:9000",
+			"admin_token":    "********",
+			"cursor_secret":  "********",
+		}},
+		{"unset secret stays empty", Config{Addr: ":8080"}, "admin-secret", http.StatusOK, map[string]string{
+			"admin_token":   "",
+			"cursor_secret": "",
+		}},
+		{"needs admin", cfg, "", http.StatusUnauthorized, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/config", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			debugRouter(chi.NewRouter(), tt.cfg).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if body := rec.Body.String(); strings.Contains(body, "admin-secret") || strings.Contains(body, "cursor-secret") {
+				t.Errorf("body leaks a secret: %s", rec.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var got map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
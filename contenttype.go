@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultContentType sets Content-Type to ct on responses whose handler
+// didn't set one, rather than letting net/http guess it from the first bytes
+// of the body, which can be wrong and, for user content, unsafe. Handlers
+// that deliberately want no Content-Type can still set the header to nil.
+func DefaultContentType(ct string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&contentTypeWriter{ResponseWriter: w, contentType: ct}, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+type contentTypeWriter struct {
+	http.ResponseWriter
+	contentType string
+	wroteHeader bool
+}
+
+func (cw *contentTypeWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		if _, ok := cw.Header()["Content-Type"]; !ok && bodyAllowed(code) {
+			cw.Header().Set("Content-Type", cw.contentType)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *contentTypeWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *contentTypeWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *contentTypeWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// errContentTypeBlocked is returned from writes of a response whose
+// Content-Type RestrictResponseContentTypes refused.
+var errContentTypeBlocked = errors.New("response content type not allowed")
+
+// RestrictResponseContentTypes makes sure handlers only ever send the media
+// types in allowed, e.g. "application/json", "text/html" or "image/*".
+// A response of any other type, including one net/http would sniff from the
+// body, is replaced with a 500 and logged, and the handler's writes fail.
+// Responses without a body are not checked.
+func RestrictResponseContentTypes(allowed []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			rw := &restrictedTypeWriter{ResponseWriter: w, r: r, allowed: allowed}
+			next.ServeHTTP(rw, r)
+			if rw.pending != 0 {
+				rw.check(rw.pending)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// restrictedTypeWriter checks the Content-Type when the response starts. A
+// status written without a Content-Type is held back until the first Write,
+// so the type net/http would sniff can be checked too.
+type restrictedTypeWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	allowed     []string
+	pending     int
+	wroteHeader bool
+	blocked     bool
+}
+
+func (rw *restrictedTypeWriter) WriteHeader(code int) {
+	if rw.wroteHeader || rw.pending != 0 {
+		return
+	}
+	if _, ok := rw.Header()["Content-Type"]; !ok && bodyAllowed(code) {
+		rw.pending = code
+		return
+	}
+	rw.check(code)
+}
+
+// check sends the response header with code, or the 500 instead if the
+// Content-Type isn't allowed.
+func (rw *restrictedTypeWriter) check(code int) {
+	rw.wroteHeader = true
+	rw.pending = 0
+	ct := rw.Header().Get("Content-Type")
+	if !bodyAllowed(code) || ct == "" || contentTypeAllowed(ct, rw.allowed) {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	rw.blocked = true
+	slog.Error("blocked response with disallowed content type",
+		"request_id", RequestID(rw.r),
+		"route", routePattern(rw.r),
+		"content_type", ct)
+	clearEntityHeaders(rw.Header())
+	writeError(rw.ResponseWriter, rw.r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// status is the status held back by WriteHeader, if any.
+func (rw *restrictedTypeWriter) status() int {
+	if rw.pending != 0 {
+		return rw.pending
+	}
+	return http.StatusOK
+}
+
+func (rw *restrictedTypeWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		if _, ok := rw.Header()["Content-Type"]; !ok && len(p) > 0 {
+			rw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		rw.check(rw.status())
+	}
+	if rw.blocked {
+		return 0, errContentTypeBlocked
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *restrictedTypeWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.check(rw.status())
+	}
+	if rw.blocked {
+		return
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *restrictedTypeWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func contentTypeAllowed(ct string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if strings.EqualFold(mediaType, a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    []string
+	}{
+		{"raw bytes", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>not really</html>"))
+		}, []string{"text/plain; charset=utf-8"}},
+		{"explicit status", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("queued"))
+		}, []string{"text/plain; charset=utf-8"}},
+		{"flushed", func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+		}, []string{"text/plain; charset=utf-8"}},
+		{"set by handler", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}, []string{"application/json"}},
+		{"deliberately none", func(w http.ResponseWriter, r *http.Request) {
+			w.Header()["Content-Type"] = nil
+			w.Write([]byte("data"))
+		}, nil},
+		{"no body", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			DefaultContentType("text/plain; charset=utf-8")(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := rec.Header().Values("Content-Type"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestrictResponseContentTypes(t *testing.T) {
+	captureLog(t)
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	serveFile := func(name, data string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Disposition", "attachment; filename="+name)
+			http.ServeContent(w, r, name, modified, strings.NewReader(data))
+		}
+	}
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"allowed", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+		}, http.StatusOK, "application/json", "{}"},
+		{"allowed by wildcard", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/webp")
+			w.Write([]byte("RIFF"))
+		}, http.StatusOK, "image/webp", "RIFF"},
+		{"allowed when sniffed", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html><body>hi</body></html>"))
+		}, http.StatusOK, "text/html; charset=utf-8", "<html><body>hi</body></html>"},
+		{"disallowed", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("internal dump"))
+		}, http.StatusInternalServerError, "application/json", `"status":500`},
+		{"disallowed when sniffed", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("%PDF-1.4"))
+		}, http.StatusInternalServerError, "application/json", `"status":500`},
+		{"no body", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusNoContent)
+		}, http.StatusNoContent, "text/plain", ""},
+		{"ServeContent allowed", serveFile("logo.png", "\x89PNG\r\n\x1a\n"), http.StatusOK, "image/png", "\x89PNG\r\n\x1a\n"},
+		{"ServeContent disallowed", serveFile("users.csv", "id,password\n1,hunter2\n"), http.StatusInternalServerError, "application/json", `"status":500`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := RestrictResponseContentTypes([]string{"application/json", "text/html", "image/*"})(tt.handler)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+			if rec.Code != http.StatusInternalServerError {
+				return
+			}
+			if strings.Contains(rec.Body.String(), "dump") || strings.Contains(rec.Body.String(), "hunter2") {
+				t.Errorf("blocked body leaked: %q", rec.Body)
+			}
+			for _, name := range []string{"Content-Length", "Content-Disposition", "Accept-Ranges", "Last-Modified", "ETag"} {
+				if v := rec.Header().Get(name); v != "" {
+					t.Errorf("%s = %q kept on the error response", name, v)
+				}
+			}
+		})
+	}
+}
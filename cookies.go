@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CookiePolicy is the set of attributes HardenCookies enforces.
+type CookiePolicy struct {
+	// Secure adds the Secure attribute to every cookie sent over HTTPS,
+	// including HTTPS a trusted proxy terminated. Over plain HTTP it is left
+	// alone, since browsers would drop the cookies.
+	Secure bool
+	// HttpOnly adds the HttpOnly attribute to every cookie except those
+	// named in ScriptReadable.
+	HttpOnly bool
+	// SameSite is given to cookies that don't set their own.
+	SameSite http.SameSite
+	// ScriptReadable names the cookies page scripts must be able to read,
+	// such as the CSRF token.
+	ScriptReadable []string
+}
+
+// DefaultCookiePolicy is Secure (over HTTPS), HttpOnly and SameSite=Lax,
+// leaving the CSRF cookie readable for the double-submit pattern.
+var DefaultCookiePolicy = CookiePolicy{
+	Secure:         true,
+	HttpOnly:       true,
+	SameSite:       http.SameSiteLaxMode,
+	ScriptReadable: []string{csrfCookieName},
+}
+
+// HardenCookies rewrites the Set-Cookie headers of every response to follow
+// policy, whichever handler or middleware set them, and collapses cookies
+// set more than once (same name, domain and path) to the last value. It
+// should be the outermost middleware that could see cookies, so it runs last.
+// Set-Cookie lines that don't parse are passed through untouched.
+func HardenCookies(policy CookiePolicy) func(next http.Handler) http.Handler {
+	readable := make(map[string]bool, len(policy.ScriptReadable))
+	for _, name := range policy.ScriptReadable {
+		readable[name] = true
+	}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			cw := &cookieWriter{
+				ResponseWriter: w,
+				policy:         policy,
+				readable:       readable,
+				secure:         policy.Secure && requestScheme(r) == "https",
+			}
+			next.ServeHTTP(cw, r)
+			// A handler that wrote nothing has its 200 sent once it returns.
+			if !cw.wroteHeader {
+				cw.hardenHeader()
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+type cookieWriter struct {
+	http.ResponseWriter
+	policy      CookiePolicy
+	readable    map[string]bool
+	secure      bool
+	wroteHeader bool
+}
+
+func (cw *cookieWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.hardenHeader()
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cookieWriter) hardenHeader() {
+	if lines := cw.Header().Values("Set-Cookie"); len(lines) > 0 {
+		cw.Header()["Set-Cookie"] = cw.harden(lines)
+	}
+}
+
+// harden applies the policy to the Set-Cookie lines, keeping each cookie
+// where it was first set but with the value it was last set to.
+func (cw *cookieWriter) harden(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	index := make(map[string]int)
+	for _, line := range lines {
+		c := parseSetCookie(line)
+		if c == nil {
+			out = append(out, line)
+			continue
+		}
+		if cw.secure {
+			c.Secure = true
+		}
+		if cw.policy.HttpOnly && !cw.readable[c.Name] {
+			c.HttpOnly = true
+		}
+		if c.SameSite == 0 {
+			c.SameSite = cw.policy.SameSite
+		}
+
+		key := c.Name + ";" + strings.ToLower(c.Domain) + ";" + c.Path
+		if i, ok := index[key]; ok {
+			out[i] = c.String()
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, c.String())
+	}
+	return out
+}
+
+// parseSetCookie parses a single Set-Cookie header value, returning nil if
+// it is invalid.
+func parseSetCookie(line string) *http.Cookie {
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": {line}}}).Cookies()
+	if len(cookies) != 1 {
+		return nil
+	}
+	return cookies[0]
+}
+
+func (cw *cookieWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *cookieWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *cookieWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHardenCookies(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	oldProxies := TrustedProxies
+	TrustedProxies = []*net.IPNet{proxies}
+	t.Cleanup(func() { TrustedProxies = oldProxies })
+
+	tests := []struct {
+		name       string
+		target     string
+		remoteAddr string
+		proto      string
+		set        []string
+		noWrite    bool
+		want       []string
+	}{
+		{
+			name:   "secure added over HTTPS",
+			target: "https://example.com/",
+			set:    []string{"session=abc; Path=/"},
+			want:   []string{"session=abc; Path=/; HttpOnly; Secure; SameSite=Lax"},
+		},
+		{
+			name:       "secure added behind a TLS-terminating proxy",
+			target:     "http://example.com/",
+			remoteAddr: "10.0.0.1:1234",
+			proto:      "https",
+			set:        []string{"session=abc; Path=/"},
+			want:       []string{"session=abc; Path=/; HttpOnly; Secure; SameSite=Lax"},
+		},
+		{
+			name:   "not secure over plain HTTP",
+			target: "http://example.com/",
+			set:    []string{"session=abc; Path=/"},
+			want:   []string{"session=abc; Path=/; HttpOnly; SameSite=Lax"},
+		},
+		{
+			name:       "spoofed proto ignored",
+			target:     "http://example.com/",
+			remoteAddr: "203.0.113.7:1234",
+			proto:      "https",
+			set:        []string{"session=abc; Path=/"},
+			want:       []string{"session=abc; Path=/; HttpOnly; SameSite=Lax"},
+		},
+		{
+			name:   "CSRF token readable, own SameSite kept",
+			target: "https://example.com/",
+			set:    []string{csrfCookieName + "=t; Path=/; SameSite=Strict"},
+			want:   []string{csrfCookieName + "=t; Path=/; Secure; SameSite=Strict"},
+		},
+		{
+			name:   "duplicates collapsed to the last value",
+			target: "https://example.com/",
+			set:    []string{"a=1; Path=/", "b=2; Path=/", "a=3; Path=/", "a=4; Path=/admin"},
+			want: []string{
+				"a=3; Path=/; HttpOnly; Secure; SameSite=Lax",
+				"b=2; Path=/; HttpOnly; Secure; SameSite=Lax",
+				"a=4; Path=/admin; HttpOnly; Secure; SameSite=Lax",
+			},
+		},
+		{
+			name:    "handler that writes nothing",
+			target:  "https://example.com/",
+			set:     []string{"session=abc; Path=/"},
+			noWrite: true,
+			want:    []string{"session=abc; Path=/; HttpOnly; Secure; SameSite=Lax"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HardenCookies(DefaultCookiePolicy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, line := range tt.set {
+					w.Header().Add("Set-Cookie", line)
+				}
+				if !tt.noWrite {
+					w.Write([]byte("ok"))
+				}
+			}))
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Values("Set-Cookie"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Set-Cookie = %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
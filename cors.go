@@ -0,0 +1,215 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins that may make cross-origin requests.
+	// "*" allows any origin, but is ignored when AllowCredentials is set.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// AllowCredentials enables credentialed mode: the request Origin is
+	// echoed back only when it is an exact match in AllowedOrigins, and
+	// Access-Control-Allow-Credentials is sent. A wildcard is never sent.
+	AllowCredentials bool
+
+	// MaxAge is how long, in seconds, browsers may cache a preflight.
+	// Defaults to 600.
+	MaxAge int
+
+	// PreflightCacheTTL is how long the server reuses the response it
+	// computed for a given origin, requested method and requested headers.
+	// Defaults to 10 minutes.
+	PreflightCacheTTL time.Duration
+}
+
+// maxPreflightCacheEntries bounds the preflight cache; it is emptied when
+// full.
+const maxPreflightCacheEntries = 1000
+
+type cors struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+type preflightEntry struct {
+	header  http.Header
+	expires time.Time
+}
+
+// CORSPolicy is a CORS configuration that can be changed while serving.
+// Preflight responses are cached per origin, requested method and requested
+// headers, and the cache is dropped whenever the configuration changes.
+type CORSPolicy struct {
+	mu    sync.RWMutex
+	c     *cors
+	ttl   time.Duration
+	cache map[string]preflightEntry
+}
+
+// NewCORSPolicy returns a CORSPolicy configured with opts.
+func NewCORSPolicy(opts CORSOptions) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.Update(opts)
+	return p
+}
+
+// Update replaces the policy's configuration, e.g. when the list of allowed
+// origins changes, and invalidates every cached preflight.
+func (p *CORSPolicy) Update(opts CORSOptions) {
+	c := newCORS(opts)
+	ttl := opts.PreflightCacheTTL
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.c = c
+	p.ttl = ttl
+	p.cache = make(map[string]preflightEntry)
+}
+
+// CORS adds Cross-Origin Resource Sharing headers for allowed origins and
+// answers preflight requests without calling the next handler. Use
+// NewCORSPolicy instead to change the configuration later.
+func CORS(opts CORSOptions) func(next http.Handler) http.Handler {
+	return NewCORSPolicy(opts).Middleware
+}
+
+func newCORS(opts CORSOptions) *cors {
+	c := &cors{
+		origins:     make(map[string]bool),
+		methods:     strings.Join(opts.AllowedMethods, ", "),
+		headers:     strings.Join(opts.AllowedHeaders, ", "),
+		credentials: opts.AllowCredentials,
+	}
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			c.anyOrigin = !opts.AllowCredentials
+			continue
+		}
+		c.origins[o] = true
+	}
+	if c.methods == "" {
+		c.methods = "GET, POST, HEAD"
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 600
+	}
+	c.maxAge = strconv.Itoa(opts.MaxAge)
+	return c
+}
+
+// Middleware applies the policy, serving repeated preflights from the cache.
+func (p *CORSPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		c := p.c
+		p.mu.RUnlock()
+
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			c.serve(w, r, next)
+			return
+		}
+
+		key := r.Header.Get("Origin") + "\n" +
+			r.Header.Get("Access-Control-Request-Method") + "\n" +
+			strings.Join(r.Header.Values("Access-Control-Request-Headers"), ",")
+		now := time.Now()
+		p.mu.RLock()
+		entry, ok := p.cache[key]
+		p.mu.RUnlock()
+		if ok && !now.Before(entry.expires) {
+			ok = false
+		}
+
+		if !ok {
+			rec := &preflightRecorder{header: make(http.Header)}
+			c.serve(rec, r, next)
+			entry = preflightEntry{header: rec.header, expires: now.Add(p.ttl)}
+			p.mu.Lock()
+			// Don't store a response computed under a configuration that
+			// has since been replaced.
+			if p.c == c {
+				if len(p.cache) >= maxPreflightCacheEntries {
+					p.cache = make(map[string]preflightEntry)
+				}
+				p.cache[key] = entry
+			}
+			p.mu.Unlock()
+		}
+
+		for k, v := range entry.header {
+			w.Header()[k] = append(w.Header()[k], v...)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// preflightRecorder captures the headers of a preflight response, which
+// always has a 204 status and no body.
+type preflightRecorder struct {
+	header http.Header
+}
+
+func (pr *preflightRecorder) Header() http.Header         { return pr.header }
+func (pr *preflightRecorder) WriteHeader(int)             {}
+func (pr *preflightRecorder) Write(p []byte) (int, error) { return len(p), nil }
+
+func (c *cors) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	origin := r.Header.Get("Origin")
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	// The response depends on the Origin whenever we echo it back, so
+	// caches must key on it even when this particular origin is denied.
+	if !c.anyOrigin {
+		w.Header().Add("Vary", "Origin")
+	}
+
+	if origin == "" || !c.allowed(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	h := w.Header()
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	h.Set("Access-Control-Allow-Methods", c.methods)
+	if c.headers != "" {
+		h.Set("Access-Control-Allow-Headers", c.headers)
+	}
+	h.Set("Access-Control-Max-Age", c.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *cors) allowed(origin string) bool {
+	return c.anyOrigin || c.origins[origin]
+}
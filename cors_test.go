@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSCredentialed(t *testing.T) {
+	mw := CORS(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "*"},
+		AllowCredentials: true,
+	})
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantOrigin string
+		wantCreds  string
+		wantStatus int
+	}{
+		{"allowed", http.MethodGet, "https://app.example.com", "https://app.example.com", "true", http.StatusOK},
+		{"denied", http.MethodGet, "https://evil.example.com", "", "", http.StatusOK},
+		{"no origin", http.MethodGet, "", "", "", http.StatusOK},
+		{"allowed preflight", http.MethodOptions, "https://app.example.com", "https://app.example.com", "true", http.StatusNoContent},
+		{"denied preflight", http.MethodOptions, "https://evil.example.com", "", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCreds {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCreds)
+			}
+			if got := rec.Header().Get("Vary"); got != "Origin" {
+				t.Errorf("Vary = %q, want Origin", got)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightCache(t *testing.T) {
+	p := NewCORSPolicy(CORSOptions{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         300,
+	})
+	h := p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("preflight reached the handler")
+	}))
+	preflight := func(headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/items", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		req.Header.Set("Access-Control-Request-Headers", headers)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := preflight("content-type")
+	if got := first.Header().Get("Access-Control-Max-Age"); got != "300" {
+		t.Errorf("Access-Control-Max-Age = %q, want 300", got)
+	}
+	// Mark the cached response, so responses served from the cache can be
+	// told apart from recomputed ones.
+	p.mu.Lock()
+	for _, entry := range p.cache {
+		entry.header.Set("X-Test-Cached", "1")
+	}
+	p.mu.Unlock()
+
+	tests := []struct {
+		name       string
+		update     *CORSOptions
+		headers    string
+		wantOrigin string
+		wantHit    bool
+	}{
+		{"identical preflight", nil, "content-type", "https://app.example.com", true},
+		{"different headers", nil, "x-custom", "https://app.example.com", false},
+		{"allow-list changed", &CORSOptions{AllowedOrigins: []string{"https://other.example.com"}}, "content-type", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.update != nil {
+				p.Update(*tt.update)
+			}
+			rec := preflight(tt.headers)
+
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want 204", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if hit := rec.Header().Get("X-Test-Cached") != ""; hit != tt.wantHit {
+				t.Errorf("served from cache = %v, want %v", hit, tt.wantHit)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	h := CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r)))
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		cookie     string
+		header     string
+		wantStatus int
+	}{
+		{"safe method without token", http.MethodGet, "", "", http.StatusOK},
+		{"valid token", http.MethodPost, "tok", "tok", http.StatusOK},
+		{"missing header", http.MethodPost, "tok", "", http.StatusForbidden},
+		{"missing cookie", http.MethodDelete, "", "tok", http.StatusForbidden},
+		{"mismatched token", http.MethodPut, "tok", "other", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(csrfHeaderName, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesToken(t *testing.T) {
+	h := CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(CSRFToken(r)))
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookieName || cookies[0].Value == "" {
+		t.Fatalf("cookies = %v, want a %s cookie", cookies, csrfCookieName)
+	}
+	if rec.Body.String() != cookies[0].Value {
+		t.Errorf("CSRFToken = %q, want the cookie's %q", rec.Body.String(), cookies[0].Value)
+	}
+}
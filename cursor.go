@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+)
+
+// CursorSecret signs pagination cursors. main sets it from CURSOR_SECRET;
+// otherwise it is random, and cursors stop working when the server restarts.
+var CursorSecret = newCursorSecret()
+
+func newCursorSecret() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// CursorPage is the response of a cursor-paginated list. Next is the cursor
+// for the following page, empty on the last one.
+type CursorPage struct {
+	Items any    `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+// NextCursor encodes lastKey, the key of the last item on a page, as an
+// opaque cursor for the page after it. Cursors are signed, so clients can't
+// forge one to start anywhere they like.
+func NextCursor(lastKey string) string {
+	payload := append(cursorMAC([]byte(lastKey)), lastKey...)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// ParseCursor returns the key a cursor from NextCursor was made from. An
+// empty token is the first page and gives an empty key. A token that was
+// not made by NextCursor, or was altered, gives a 400 HTTPError.
+func ParseCursor(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(payload) < sha256.Size {
+		return "", &HTTPError{Status: http.StatusBadRequest, Message: "invalid cursor", Err: err}
+	}
+	mac, key := payload[:sha256.Size], payload[sha256.Size:]
+	if !hmac.Equal(mac, cursorMAC(key)) {
+		return "", &HTTPError{Status: http.StatusBadRequest, Message: "invalid cursor"}
+	}
+	return string(key), nil
+}
+
+func cursorMAC(key []byte) []byte {
+	mac := hmac.New(sha256.New, CursorSecret)
+	mac.Write(key)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	oldSecret := CursorSecret
+	CursorSecret = []byte("cursor-secret")
+	t.Cleanup(func() { CursorSecret = oldSecret })
+
+	valid := NextCursor("user-42")
+	payload, _ := base64.RawURLEncoding.DecodeString(valid)
+	forged := base64.RawURLEncoding.EncodeToString(append(payload[:len(payload)-2:len(payload)-2], "99"...))
+	flipped := append([]byte(nil), payload...)
+	flipped[0] ^= 1
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{"round trip", valid, "user-42", false},
+		{"first page", "", "", false},
+		{"empty key", NextCursor(""), "", false},
+		{"unusual key", NextCursor("a/b?c=d&e\x00"), "a/b?c=d&e\x00", false},
+		{"key changed", forged, "", true},
+		{"signature changed", base64.RawURLEncoding.EncodeToString(flipped), "", true},
+		{"truncated", valid[:10], "", true},
+		{"not base64", "!!!", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCursor(tt.token)
+			if tt.wantErr {
+				var herr *HTTPError
+				if !errors.As(err, &herr) || herr.Status != http.StatusBadRequest {
+					t.Errorf("err = %v, want a 400 HTTPError", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseCursor = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("other secret", func(t *testing.T) {
+		CursorSecret = []byte("rotated")
+		defer func() { CursorSecret = []byte("cursor-secret") }()
+		if _, err := ParseCursor(valid); err == nil {
+			t.Error("cursor signed with another secret accepted")
+		}
+	})
+}
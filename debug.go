@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// envFlag reports whether the environment variable name is set to a true
+// value such as "1" or "true".
+func envFlag(name string) bool {
+	on, _ := strconv.ParseBool(os.Getenv(name))
+	return on
+}
+
+// debugRouter returns the /debug endpoints for introspecting root. They
+// expose internals, so main only mounts them when DEBUG is set. The loaded
+// configuration additionally requires the admin token.
+func debugRouter(root chi.Routes, cfg Config) chi.Router {
+	r := chi.NewRouter()
+	r.Get("/routes", routesHandler(root))
+	r.Get("/latency", latencyHandler)
+	r.Get("/runtime", runtimeHandler)
+	r.Get("/examples", examplesHandler)
+	r.Get("/errors", recentErrorsHandler)
+	r.With(RequireAdmin).Get("/config", configHandler(cfg))
+	return r
+}
+
+// configHandler shows cfg with its secrets redacted.
+func configHandler(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, cfg.Redacted())
+	}
+}
+
+type routeInfo struct {
+	Method      string `json:"method"`
+	Pattern     string `json:"pattern"`
+	Middlewares int    `json:"middlewares"`
+}
+
+// routesHandler lists every route registered on root.
+func routesHandler(root chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		routes := []routeInfo{}
+		err := chi.Walk(root, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			routes = append(routes, routeInfo{Method: method, Pattern: route, Middlewares: len(middlewares)})
+			return nil
+		})
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		render.JSON(w, r, routes)
+	}
+}
+
+// startTime is when the process started, for reporting uptime.
+var startTime = time.Now()
+
+type runtimeStats struct {
+	Goroutines    int     `json:"goroutines"`
+	AllocBytes    uint64  `json:"alloc_bytes"`
+	SysBytes      uint64  `json:"sys_bytes"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	NumGC         uint32  `json:"num_gc"`
+	PauseTotalMS  float64 `json:"gc_pause_total_ms"`
+	LastPauseMS   float64 `json:"gc_last_pause_ms"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// runtimeHandler reports goroutine and memory statistics, a lighter-weight
+// alternative to pprof for dashboards.
+func runtimeHandler(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	render.JSON(w, r, runtimeStats{
+		Goroutines:    runtime.NumGoroutine(),
+		AllocBytes:    m.Alloc,
+		SysBytes:      m.Sys,
+		HeapObjects:   m.HeapObjects,
+		NumGC:         m.NumGC,
+		PauseTotalMS:  float64(m.PauseTotalNs) / 1e6,
+		LastPauseMS:   float64(m.PauseNs[(m.NumGC+255)%256]) / 1e6,
+		UptimeSeconds: time.Since(startTime).Seconds(),
+	})
+}
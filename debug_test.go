@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRuntimeHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	runtimeHandler(rec, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body %q is not JSON: %v", rec.Body.String(), err)
+	}
+
+	tests := []struct {
+		field    string
+		positive bool
+	}{
+		{"goroutines", true},
+		{"alloc_bytes", true},
+		{"sys_bytes", true},
+		{"heap_objects", true},
+		{"num_gc", false},
+		{"gc_pause_total_ms", false},
+		{"gc_last_pause_ms", false},
+		{"uptime_seconds", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			v, ok := got[tt.field].(float64)
+			if !ok {
+				t.Fatalf("%s = %v, want a number", tt.field, got[tt.field])
+			}
+			if v < 0 || tt.positive && v == 0 {
+				t.Errorf("%s = %v, want a positive number", tt.field, v)
+			}
+		})
+	}
+}
+
+func TestDebugRouterRoutes(t *testing.T) {
+	r := debugRouter(chi.NewRouter(), Config{})
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/runtime", http.StatusOK},
+		{"/latency", http.StatusOK},
+		{"/errors", http.StatusOK},
+		{"/missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
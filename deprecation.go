@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Deprecated marks the routes it wraps as deprecated, telling API consumers
+// programmatically: it sets Deprecation: true, a Sunset header (RFC 8594)
+// with the HTTP-date the routes go away, and, when link is set, a Link to
+// the migration docs.
+func Deprecated(sunset time.Time, link string) func(next http.Handler) http.Handler {
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", "true")
+			h.Set("Sunset", sunsetDate)
+			if link != "" {
+				h.Add("Link", "<"+link+`>; rel="deprecation"`)
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
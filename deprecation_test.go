@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	tests := []struct {
+		name       string
+		sunset     time.Time
+		link       string
+		wantSunset string
+		wantLink   []string
+	}{
+		{
+			name:       "utc",
+			sunset:     time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+			link:       "https://docs.example.com/migrate",
+			wantSunset: "Sat, 01 Mar 2025 00:00:00 GMT",
+			wantLink:   []string{`<https://docs.example.com/migrate>; rel="deprecation"`},
+		},
+		{
+			name:       "converted to GMT",
+			sunset:     time.Date(2025, time.March, 1, 9, 30, 0, 0, time.FixedZone("JST", 9*3600)),
+			wantSunset: "Sat, 01 Mar 2025 00:30:00 GMT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Deprecated(tt.sunset, tt.link)(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+
+			if got := rec.Header().Get("Deprecation"); got != "true" {
+				t.Errorf("Deprecation = %q, want true", got)
+			}
+			got := rec.Header().Get("Sunset")
+			if got != tt.wantSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.wantSunset)
+			}
+			if parsed, err := http.ParseTime(got); err != nil || !parsed.Equal(tt.sunset) {
+				t.Errorf("Sunset %q parses as %v (%v), want %v", got, parsed, err, tt.sunset)
+			}
+			if got := rec.Header()["Link"]; !reflect.DeepEqual(got, tt.wantLink) {
+				t.Errorf("Link = %q, want %q", got, tt.wantLink)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ServeDownload sends the file at path as an attachment named filename, so
+// that browsers save it rather than display it. Range requests are
+// supported. A missing file is returned as a 404 HTTPError for Handler to
+// render.
+func ServeDownload(w http.ResponseWriter, r *http.Request, path, filename string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &HTTPError{Status: http.StatusNotFound, Message: "file not found", Err: err}
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &HTTPError{Status: http.StatusNotFound, Message: "file not found"}
+	}
+
+	filename = sanitizeFilename(filename)
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", contentDisposition(filename))
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+	return nil
+}
+
+// contentDisposition builds an attachment header carrying filename both as a
+// plain quoted string and RFC 5987 encoded, for non-ASCII names.
+func contentDisposition(filename string) string {
+	return `attachment; filename="` + filename + `"; filename*=UTF-8''` + url.PathEscape(filename)
+}
+
+// sanitizeFilename strips anything from filename that could break out of the
+// Content-Disposition header or name a path on the client.
+func sanitizeFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case r == '"' || r == '\\' || r == '/':
+			return '_'
+		}
+		return r
+	}, filename)
+	filename = strings.TrimSpace(filename)
+	if filename == "" || filename == "." || filename == ".." {
+		return "download"
+	}
+	return filename
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeDownload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(path, []byte("a,b\n1,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		filename        string
+		wantStatus      int
+		wantDisposition string
+		wantType        string
+	}{
+		{"attachment", path, "report.csv", http.StatusOK, `attachment; filename="report.csv"; filename*=UTF-8''report.csv`, "text/csv; charset=utf-8"},
+		{"non-ASCII and quotes", path, `résumé "v2".csv`, http.StatusOK, `attachment; filename="résumé _v2_.csv"; filename*=UTF-8''r%C3%A9sum%C3%A9%20_v2_.csv`, "text/csv; charset=utf-8"},
+		{"path in name", path, "../../etc/passwd", http.StatusOK, `attachment; filename=".._.._etc_passwd"; filename*=UTF-8''.._.._etc_passwd`, "application/octet-stream"},
+		{"missing file", filepath.Join(dir, "nope.csv"), "nope.csv", http.StatusNotFound, "", ""},
+		{"directory", dir, "dir", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				return ServeDownload(w, r, tt.path, tt.filename)
+			})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/download", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != tt.wantDisposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.wantDisposition)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if rec.Body.String() != "a,b\n1,2\n" {
+				t.Errorf("body = %q", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	// maxRecentErrors is how many errors /debug/errors keeps.
+	maxRecentErrors = 100
+	// maxErrorMessageBytes bounds each kept error message.
+	maxErrorMessageBytes = 512
+)
+
+type recentError struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Status    int       `json:"status"`
+	Message   string    `json:"message"`
+}
+
+var recentErrors struct {
+	sync.Mutex
+	ring [maxRecentErrors]recentError
+	next int
+	n    int
+}
+
+// recordError keeps an error for /debug/errors, overwriting the oldest once
+// maxRecentErrors are kept.
+func recordError(r *http.Request, status int, msg string) {
+	if len(msg) > maxErrorMessageBytes {
+		msg = strings.ToValidUTF8(msg[:maxErrorMessageBytes], "") + "…"
+	}
+	e := recentError{
+		Time:      time.Now(),
+		RequestID: RequestID(r),
+		Method:    r.Method,
+		Route:     routePattern(r),
+		Status:    status,
+		Message:   msg,
+	}
+
+	recentErrors.Lock()
+	defer recentErrors.Unlock()
+	recentErrors.ring[recentErrors.next] = e
+	recentErrors.next = (recentErrors.next + 1) % maxRecentErrors
+	recentErrors.n = min(recentErrors.n+1, maxRecentErrors)
+}
+
+// recentErrorsHandler lists the errors returned by Handlers and the panics
+// caught by Recover, newest first.
+func recentErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	recentErrors.Lock()
+	errs := make([]recentError, recentErrors.n)
+	for i := range errs {
+		errs[i] = recentErrors.ring[(recentErrors.next-1-i+maxRecentErrors)%maxRecentErrors]
+	}
+	recentErrors.Unlock()
+	render.JSON(w, r, errs)
+}
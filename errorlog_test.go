@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRecentErrors(t *testing.T) {
+	captureLog(t)
+	recentErrors.Lock()
+	saved := recentErrors.ring
+	savedNext, savedN := recentErrors.next, recentErrors.n
+	recentErrors.next, recentErrors.n = 0, 0
+	recentErrors.Unlock()
+	t.Cleanup(func() {
+		recentErrors.Lock()
+		recentErrors.ring, recentErrors.next, recentErrors.n = saved, savedNext, savedN
+		recentErrors.Unlock()
+	})
+
+	r := chi.NewRouter()
+	r.Use(Recover)
+	r.Method(http.MethodPut, "/items/{id}", Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return &HTTPError{Status: http.StatusConflict, Message: "conflict", Err: errors.New("version mismatch")}
+	}))
+	r.Method(http.MethodGet, "/long", Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New(strings.Repeat("é", maxErrorMessageBytes))
+	}))
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("kaboom")
+	})
+	r.Get("/ok", okHandler.ServeHTTP)
+	r.Get("/debug/errors", recentErrorsHandler)
+
+	list := func() []recentError {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/errors", nil))
+		var errs []recentError
+		if err := json.Unmarshal(rec.Body.Bytes(), &errs); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+		return errs
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantRoute  string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"returned error", http.MethodPut, "/items/7", "/items/{id}", http.StatusConflict, "conflict: version mismatch"},
+		{"panic", http.MethodGet, "/panic", "/panic", http.StatusInternalServerError, "panic: kaboom"},
+		{"success not recorded", http.MethodGet, "/ok", "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(list())
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			errs := list()
+			if tt.wantStatus == 0 {
+				if len(errs) != before {
+					t.Errorf("recorded %d errors, want %d", len(errs), before)
+				}
+				return
+			}
+			if len(errs) != before+1 {
+				t.Fatalf("recorded %d errors, want %d", len(errs), before+1)
+			}
+			got := errs[0]
+			if got.Method != tt.method || got.Route != tt.wantRoute || got.Status != tt.wantStatus || got.Message != tt.wantMsg {
+				t.Errorf("newest error = %+v, want %s %s %d %q", got, tt.method, tt.wantRoute, tt.wantStatus, tt.wantMsg)
+			}
+			if got.Time.IsZero() || got.RequestID == "" {
+				t.Errorf("newest error = %+v, want a time and request ID", got)
+			}
+		})
+	}
+
+	t.Run("message truncated", func(t *testing.T) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/long", nil))
+		msg := list()[0].Message
+		if len(msg) > maxErrorMessageBytes+len("…") || !strings.HasSuffix(msg, "…") || !utf8.ValidString(msg) {
+			t.Errorf("message = %d bytes %.20q…, want it cut to %d valid bytes", len(msg), msg, maxErrorMessageBytes)
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		for i := 0; i < maxRecentErrors+5; i++ {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/items/"+strconv.Itoa(i), nil))
+		}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+		errs := list()
+		if len(errs) != maxRecentErrors {
+			t.Fatalf("kept %d errors, want %d", len(errs), maxRecentErrors)
+		}
+		if errs[0].Route != "/panic" || errs[1].Route != "/items/{id}" {
+			t.Errorf("not newest first: %s, %s", errs[0].Route, errs[1].Route)
+		}
+	})
+}
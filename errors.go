@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/go-chi/render"
+)
+
+// errorResponse is the JSON envelope used for every error we render.
+type errorResponse struct {
+	Error     string `json:"error"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeError renders the standard JSON error envelope with the given status.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	render.Status(r, status)
+	render.JSON(w, r, errorResponse{Error: msg, Status: status, RequestID: RequestID(r)})
+}
+
+// ErrorPages maps statuses to handlers that render a custom page for them,
+// e.g. a branded "slow down" page for 429. The limiters (rate limits,
+// concurrency and bandwidth caps, body size limits, warmup, readiness
+// gating and maintenance mode), and Handler for the HTTPErrors handlers
+// return, use the page registered for their status instead of their default
+// body. Register pages before serving.
+var ErrorPages = map[int]http.Handler{}
+
+// serveErrorPage serves the page registered in ErrorPages for status, if
+// there is one, and reports whether it did. The response status is always
+// status, whatever the page handler writes.
+func serveErrorPage(w http.ResponseWriter, r *http.Request, status int) bool {
+	page, ok := ErrorPages[status]
+	if !ok || page == nil {
+		return false
+	}
+	page.ServeHTTP(&fixedStatusWriter{ResponseWriter: w, status: status}, r)
+	return true
+}
+
+// writeLimitError is writeError for requests turned away by a limiter or
+// failed with an HTTPError: it serves the custom page for status when one
+// is registered.
+func writeLimitError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if !serveErrorPage(w, r, status) {
+		writeError(w, r, status, msg)
+	}
+}
+
+// fixedStatusWriter sends status whatever status its handler writes.
+type fixedStatusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (fw *fixedStatusWriter) WriteHeader(int) {
+	if !fw.wroteHeader {
+		fw.wroteHeader = true
+		fw.ResponseWriter.WriteHeader(fw.status)
+	}
+}
+
+func (fw *fixedStatusWriter) Write(p []byte) (int, error) {
+	fw.WriteHeader(fw.status)
+	return fw.ResponseWriter.Write(p)
+}
+
+func (fw *fixedStatusWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
+
+// ValidationError collects field-level validation failures. Handlers build
+// one up with Add and return it; Handler renders it as a 422 listing every
+// field.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+// Add records msg against field.
+func (e *ValidationError) Add(field, msg string) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	e.Fields[field] = msg
+}
+
+// Err returns e if any field failed validation, and nil otherwise, so
+// handlers can end with `return verr.Err()`.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for f := range e.Fields {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for i, f := range fields {
+		fields[i] = f + ": " + e.Fields[f]
+	}
+	return "validation failed: " + strings.Join(fields, "; ")
+}
+
+type validationResponse struct {
+	errorResponse
+	Fields map[string]string `json:"fields"`
+}
+
+func writeValidationError(w http.ResponseWriter, r *http.Request, e *ValidationError) {
+	render.Status(r, http.StatusUnprocessableEntity)
+	render.JSON(w, r, validationResponse{
+		errorResponse: errorResponse{Error: "validation failed", Status: http.StatusUnprocessableEntity, RequestID: RequestID(r)},
+		Fields:        e.Fields,
+	})
+}
+
+// HTTPError is an error that tells Handler which status to respond with.
+type HTTPError struct {
+	Status  int
+	Message string
+	// Err is the underlying cause, if any. It is not shown to clients.
+	Err error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// maxEnvelopeBytes bounds how much of a response ErrorEnvelope holds back.
+// Error bodies are small; anything larger is streamed untouched.
+const maxEnvelopeBytes = 64 << 10
+
+// ErrorEnvelope rewrites 4xx and 5xx responses that aren't already JSON into
+// the standard error envelope, keeping their status. It is meant for groups
+// serving third-party http.Handlers, which write their own error bodies.
+func ErrorEnvelope(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		bw := newBufferedWriter(w, maxEnvelopeBytes)
+		next.ServeHTTP(bw, r)
+
+		status := bw.Status()
+		if !bw.Buffered() || status < 400 || isJSON(w.Header().Get("Content-Type")) {
+			bw.release()
+			return
+		}
+		clearEntityHeaders(w.Header())
+		writeError(w, r, status, http.StatusText(status))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// entityHeaders describe a response body, and must not outlive it when the
+// body is replaced with an error.
+var entityHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Encoding",
+	"Content-Disposition",
+	"Content-Language",
+	"Content-Range",
+	"Accept-Ranges",
+	"Last-Modified",
+	"ETag",
+}
+
+// clearEntityHeaders removes entityHeaders from h, before a response is
+// replaced with an error.
+func clearEntityHeaders(h http.Header) {
+	for _, name := range entityHeaders {
+		h.Del(name)
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// clientGone reports whether err is the result of the client disconnecting,
+// in which case there is nobody left to send an error response to. A
+// context.Canceled only counts if it was r's own context that was cancelled,
+// rather than, say, a call the handler gave up on.
+func clientGone(r *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) && errors.Is(r.Context().Err(), context.Canceled) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, net.ErrClosed)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationErrorJSON(t *testing.T) {
+	h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+		var verr ValidationError
+		verr.Add("email", "is required")
+		verr.Add("age", "must be positive")
+		return verr.Err()
+	})
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body is not JSON: %v: %s", err, rec.Body)
+	}
+	if got["error"] != "validation failed" || got["status"] != float64(422) || got["request_id"] == "" {
+		t.Errorf("envelope = %v", got)
+	}
+	wantFields := map[string]any{"email": "is required", "age": "must be positive"}
+	if !reflect.DeepEqual(got["fields"], wantFields) {
+		t.Errorf("fields = %v, want %v", got["fields"], wantFields)
+	}
+}
+
+func TestValidationErrorErr(t *testing.T) {
+	var verr ValidationError
+	if verr.Err() != nil {
+		t.Error("Err() of an empty ValidationError is not nil")
+	}
+	verr.Add("name", "too long")
+	if err := verr.Err(); err == nil || err.Error() != "validation failed: name: too long" {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      http.HandlerFunc
+		wantStatus   int
+		wantEnvelope bool
+		wantBody     string
+	}{
+		{
+			name: "plain-text 500",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "database exploded", http.StatusInternalServerError)
+			},
+			wantStatus:   http.StatusInternalServerError,
+			wantEnvelope: true,
+		},
+		{
+			name:         "stdlib 404",
+			handler:      http.NotFound,
+			wantStatus:   http.StatusNotFound,
+			wantEnvelope: true,
+		},
+		{
+			name: "JSON error kept",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"title":"bad"}`))
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"title":"bad"}`,
+		},
+		{
+			name: "success untouched",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ErrorEnvelope(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !tt.wantEnvelope {
+				if rec.Body.String() != tt.wantBody {
+					t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+				}
+				return
+			}
+			var got errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q is not the envelope: %v", rec.Body.String(), err)
+			}
+			want := errorResponse{Error: http.StatusText(tt.wantStatus), Status: tt.wantStatus}
+			got.RequestID = ""
+			if got != want {
+				t.Errorf("envelope = %+v, want %+v", got, want)
+			}
+			if ct := rec.Header().Get("Content-Type"); !isJSON(ct) {
+				t.Errorf("Content-Type = %q, want JSON", ct)
+			}
+		})
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	oldPages := ErrorPages
+	t.Cleanup(func() { ErrorPages = oldPages })
+	ErrorPages = map[int]http.Handler{
+		http.StatusTooManyRequests: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("<h1>Slow down</h1>"))
+		}),
+		http.StatusRequestEntityTooLarge: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<h1>Too big</h1>"))
+		}),
+	}
+
+	limited := RateLimit(1, time.Minute, KeyByIP)(okHandler)
+	upload := EnforceMultipart(16, 10)(uploadHandler(t.TempDir(), time.Minute))
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		req        func() *http.Request
+		wantStatus int
+		wantBody   string
+	}{
+		{"under the rate limit", limited, func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/", nil)
+		}, http.StatusOK, "ok"},
+		{"rate limited", limited, func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/", nil)
+		}, http.StatusTooManyRequests, "<h1>Slow down</h1>"},
+		{"returned HTTPError", upload, func() *http.Request {
+			body, contentType := multipartBody(t, 1, 64)
+			req := httptest.NewRequest(http.MethodPost, "/upload", io.NopCloser(body))
+			req.ContentLength = -1
+			req.Header.Set("Content-Type", contentType)
+			return req
+		}, http.StatusRequestEntityTooLarge, "<h1>Too big</h1>"},
+		{"no page registered", Handler(func(w http.ResponseWriter, r *http.Request) error {
+			return &HTTPError{Status: http.StatusConflict, Message: "conflict"}
+		}), func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/", nil)
+		}, http.StatusConflict, `"error":"conflict"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, tt.req())
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
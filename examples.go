@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// maxExampleBytes caps each recorded request and response body.
+const maxExampleBytes = 4 << 10
+
+type example struct {
+	Method       string `json:"method"`
+	Pattern      string `json:"pattern"`
+	RequestBody  string `json:"request_body,omitempty"`
+	Status       int    `json:"status"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Truncated    bool   `json:"truncated,omitempty"`
+}
+
+var examples = struct {
+	sync.Mutex
+	routes map[string]*example
+}{routes: make(map[string]*example)}
+
+// RecordExamples keeps the first request and response seen for each route,
+// bodies capped at 4KB, as a starting point for API documentation. They are
+// listed at /debug/examples. Unmatched requests are not recorded.
+func RecordExamples(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		reqBody := &cappedBuffer{limit: maxExampleBytes}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		respBody := &cappedBuffer{limit: maxExampleBytes}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(respBody)
+
+		next.ServeHTTP(ww, r)
+
+		pattern := routePattern(r)
+		if pattern == "" {
+			return
+		}
+		key := r.Method + " " + pattern
+		examples.Lock()
+		defer examples.Unlock()
+		if _, ok := examples.routes[key]; ok {
+			return
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		examples.routes[key] = &example{
+			Method:       r.Method,
+			Pattern:      pattern,
+			RequestBody:  reqBody.String(),
+			Status:       status,
+			ResponseBody: respBody.String(),
+			Truncated:    reqBody.truncated || respBody.truncated,
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (cb *cappedBuffer) Write(p []byte) (int, error) {
+	if room := cb.limit - cb.Len(); len(p) > room {
+		cb.truncated = true
+		cb.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return cb.Buffer.Write(p)
+}
+
+// examplesHandler lists the recorded examples, sorted by route.
+func examplesHandler(w http.ResponseWriter, r *http.Request) {
+	examples.Lock()
+	list := make([]example, 0, len(examples.routes))
+	for _, ex := range examples.routes {
+		list = append(list, *ex)
+	}
+	examples.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Pattern != list[j].Pattern {
+			return list[i].Pattern < list[j].Pattern
+		}
+		return list[i].Method < list[j].Method
+	})
+	render.JSON(w, r, list)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRecordExamples(t *testing.T) {
+	examples.Lock()
+	oldRoutes := examples.routes
+	examples.routes = make(map[string]*example)
+	examples.Unlock()
+	t.Cleanup(func() {
+		examples.Lock()
+		examples.routes = oldRoutes
+		examples.Unlock()
+	})
+
+	var sawNoBody bool
+	r := chi.NewRouter()
+	r.Use(RecordExamples)
+	r.Post("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]any
+		json.NewDecoder(r.Body).Decode(&in)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"` + chi.URLParam(r, "id") + `"}`))
+	})
+	r.Get("/big", func(w http.ResponseWriter, r *http.Request) {
+		sawNoBody = r.Body == http.NoBody
+		w.Write([]byte(strings.Repeat("x", maxExampleBytes+1)))
+	})
+	r.Get("/debug/examples", examplesHandler)
+
+	requests := []struct {
+		method, target string
+		body           io.Reader
+	}{
+		{http.MethodPost, "/users/1", strings.NewReader(`{"name":"ann"}`)},
+		{http.MethodPost, "/users/2", strings.NewReader(`{"name":"bob"}`)},
+		{http.MethodGet, "/big", nil},
+		{http.MethodGet, "/missing", nil},
+	}
+	for _, req := range requests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.target, req.body))
+	}
+	if !sawNoBody {
+		t.Error("an empty request body was wrapped")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/examples", nil))
+	var got []example
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	want := []example{
+		{Method: "GET", Pattern: "/big", Status: http.StatusOK, ResponseBody: strings.Repeat("x", maxExampleBytes), Truncated: true},
+		{Method: "POST", Pattern: "/users/{id}", RequestBody: `{"name":"ann"}`, Status: http.StatusCreated, ResponseBody: `{"id":"1"}`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("examples = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed static/favicon.ico
+var defaultFavicon []byte
+
+// Favicon serves data at /favicon.ico with a long cache lifetime, so that
+// browsers' constant requests for it stop filling the logs with 404s. With
+// no data it answers 204 No Content instead.
+func Favicon(r chi.Router, data []byte) {
+	contentType := http.DetectContentType(data)
+	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		if len(data) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "public, max-age=31536000")
+		w.Write(data)
+	})
+}
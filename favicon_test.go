@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFavicon(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name       string
+		data       []byte
+		wantStatus int
+		wantType   string
+		wantCache  string
+	}{
+		{"default icon", defaultFavicon, http.StatusOK, "image/x-icon", "public, max-age=31536000"},
+		{"png icon", png, http.StatusOK, "image/png", "public, max-age=31536000"},
+		{"no icon", nil, http.StatusNoContent, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			Favicon(r, tt.data)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if !bytes.Equal(rec.Body.Bytes(), tt.data) {
+				t.Errorf("body is %d bytes, want %d", rec.Body.Len(), len(tt.data))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultGzipSkipTypes are content types FileServer never compresses because
+// they are compressed already. Entries ending in "/" match a whole family.
+var DefaultGzipSkipTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/pdf",
+}
+
+// acceptsGzip reports whether r's Accept-Encoding allows a gzip response.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+		if (strings.EqualFold(coding, "gzip") || coding == "*") && quality(params) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipFileWriter compresses a full (200) file response once its headers show
+// it is big enough and of a type worth compressing.
+type gzipFileWriter struct {
+	http.ResponseWriter
+	minSize     int64
+	skip        []string
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipFileWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	if code == http.StatusOK && gw.compressible() {
+		h := gw.Header()
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", "gzip")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(code)
+}
+
+func (gw *gzipFileWriter) compressible() bool {
+	h := gw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	size, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if err != nil || size < gw.minSize {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range gw.skip {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (gw *gzipFileWriter) Write(p []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(p)
+	}
+	return gw.ResponseWriter.Write(p)
+}
+
+// Close finishes the gzip stream, if the response is being compressed.
+func (gw *gzipFileWriter) Close() error {
+	if gw.gz != nil {
+		return gw.gz.Close()
+	}
+	return nil
+}
+
+func (gw *gzipFileWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
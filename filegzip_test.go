@@ -0,0 +1,75 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFileServerGzip(t *testing.T) {
+	css := strings.Repeat("body { color: red; }\n", 100)
+	files := http.FS(fstest.MapFS{
+		"site.css":  {Data: []byte(css)},
+		"tiny.css":  {Data: []byte("a{}")},
+		"photo.png": {Data: append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 4096)...)},
+	})
+	r := chi.NewRouter()
+	FileServerWithOptions(r, "/static", files, FileServerOptions{GzipMinSize: 1 << 10})
+
+	tests := []struct {
+		name       string
+		path       string
+		encoding   string
+		rangeHdr   string
+		wantStatus int
+		wantGzip   bool
+	}{
+		{"large css", "/static/site.css", "gzip, deflate", "", http.StatusOK, true},
+		{"png", "/static/photo.png", "gzip", "", http.StatusOK, false},
+		{"small css", "/static/tiny.css", "gzip", "", http.StatusOK, false},
+		{"no gzip accepted", "/static/site.css", "identity", "", http.StatusOK, false},
+		{"gzip refused", "/static/site.css", "gzip;q=0", "", http.StatusOK, false},
+		{"range", "/static/site.css", "gzip", "bytes=0-9", http.StatusPartialContent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.encoding)
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Fatalf("gzipped = %v, want %v", got, tt.wantGzip)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			if !tt.wantGzip {
+				return
+			}
+			if rec.Header().Get("Content-Length") != "" {
+				t.Error("gzipped response kept the uncompressed Content-Length")
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(zr)
+			if err != nil || string(body) != css {
+				t.Errorf("decompressed body differs (err %v)", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// notFoundPage is the data FileServerOptions.NotFoundTemplate is executed
+// with.
+type notFoundPage struct {
+	Path      string
+	RequestID string
+}
+
+// notFoundWriter holds back http.FileServer's plain-text 404 so that a
+// templated page can be sent in its place. Other responses pass through.
+type notFoundWriter struct {
+	http.ResponseWriter
+	notFound    bool
+	wroteHeader bool
+}
+
+func (nw *notFoundWriter) WriteHeader(code int) {
+	if nw.wroteHeader {
+		return
+	}
+	nw.wroteHeader = true
+	if code == http.StatusNotFound {
+		nw.notFound = true
+		return
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *notFoundWriter) Write(p []byte) (int, error) {
+	if !nw.wroteHeader {
+		nw.WriteHeader(http.StatusOK)
+	}
+	if nw.notFound {
+		return len(p), nil
+	}
+	return nw.ResponseWriter.Write(p)
+}
+
+func (nw *notFoundWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
+
+// serveNotFoundPage answers with tmpl executed for r, falling back to a
+// plain-text 404 if it fails.
+func serveNotFoundPage(w http.ResponseWriter, r *http.Request, tmpl *template.Template) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, notFoundPage{Path: r.URL.Path, RequestID: RequestID(r)}); err != nil {
+		slog.Error("rendering not-found page", "request_id", RequestID(r), "err", err)
+		w.Header().Del("Content-Type")
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(buf.Bytes())
+}
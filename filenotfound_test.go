@@ -0,0 +1,56 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestNotFoundTemplate(t *testing.T) {
+	captureLog(t)
+	files := http.FS(fstest.MapFS{"a.txt": {Data: []byte("hello")}})
+	page := template.Must(template.New("404").Parse(`<p>No file at {{.Path}} ({{.RequestID}})</p>`))
+	broken := template.Must(template.New("404").Parse(`{{.Missing}}`))
+
+	tests := []struct {
+		name       string
+		tmpl       *template.Template
+		path       string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"templated", page, "/files/nope.txt", http.StatusNotFound, "text/html; charset=utf-8", "<p>No file at /files/nope.txt (req-1)</p>"},
+		{"path escaped", page, "/files/<b>.txt", http.StatusNotFound, "text/html; charset=utf-8", "<p>No file at /files/&lt;b&gt;.txt (req-1)</p>"},
+		{"no template", nil, "/files/nope.txt", http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"template fails", broken, "/files/nope.txt", http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"file found", page, "/files/a.txt", http.StatusOK, "text/plain; charset=utf-8", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(middleware.RequestID)
+			FileServerWithOptions(r, "/files", files, FileServerOptions{NotFoundTemplate: tt.tmpl})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path = tt.path
+			req.Header.Set(middleware.RequestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
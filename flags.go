@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+var (
+	flagsMu sync.RWMutex
+	flags   = make(map[string]bool)
+)
+
+type flagsKey struct{}
+
+// SetFlag switches the named feature flag on or off. Requests already being
+// served keep the value they started with.
+func SetFlag(name string, on bool) {
+	flagsMu.Lock()
+	defer flagsMu.Unlock()
+	flags[name] = on
+}
+
+// flagSnapshot copies the current flags.
+func flagSnapshot() map[string]bool {
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	snap := make(map[string]bool, len(flags))
+	for name, on := range flags {
+		snap[name] = on
+	}
+	return snap
+}
+
+// FeatureFlags takes a snapshot of the feature flags as each request starts,
+// so that a request sees one consistent set even if a flag is toggled while
+// it is being served.
+func FeatureFlags(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), flagsKey{}, flagSnapshot())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// FlagEnabled reports whether the named feature flag is on for the request
+// ctx belongs to. Unknown flags are off. Outside FeatureFlags it reads the
+// current value.
+func FlagEnabled(ctx context.Context, name string) bool {
+	if snap, ok := ctx.Value(flagsKey{}).(map[string]bool); ok {
+		return snap[name]
+	}
+	flagsMu.RLock()
+	defer flagsMu.RUnlock()
+	return flags[name]
+}
+
+// flagHandler toggles the flag named in the path, or sets it with
+// ?enabled=true|false, and reports all flags.
+func flagHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	flagsMu.RLock()
+	on := !flags[name]
+	flagsMu.RUnlock()
+	if v := r.URL.Query().Get("enabled"); v != "" {
+		var err error
+		if on, err = strconv.ParseBool(v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "enabled must be true or false")
+			return
+		}
+	}
+	SetFlag(name, on)
+	render.JSON(w, r, flagSnapshot())
+}
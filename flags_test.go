@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	oldToken := AdminToken
+	AdminToken = "admin-secret"
+	t.Cleanup(func() {
+		AdminToken = oldToken
+		flagsMu.Lock()
+		delete(flags, "new-checkout")
+		flagsMu.Unlock()
+	})
+
+	r := chi.NewRouter()
+	r.Use(FeatureFlags)
+	r.Mount("/admin", adminRouter())
+	r.Get("/checkout", func(w http.ResponseWriter, r *http.Request) {
+		if FlagEnabled(r.Context(), "new-checkout") {
+			w.Write([]byte("new"))
+			return
+		}
+		w.Write([]byte("old"))
+	})
+
+	tests := []struct {
+		name       string
+		toggle     string
+		token      string
+		wantStatus int
+		want       string
+	}{
+		{"off by default", "", "", 0, "old"},
+		{"toggled on", "/admin/flags/new-checkout", "admin-secret", http.StatusOK, "new"},
+		{"toggled off", "/admin/flags/new-checkout", "admin-secret", http.StatusOK, "old"},
+		{"set explicitly", "/admin/flags/new-checkout?enabled=true", "admin-secret", http.StatusOK, "new"},
+		{"set again is idempotent", "/admin/flags/new-checkout?enabled=true", "admin-secret", http.StatusOK, "new"},
+		{"bad value", "/admin/flags/new-checkout?enabled=maybe", "admin-secret", http.StatusBadRequest, "new"},
+		{"not an admin", "/admin/flags/new-checkout", "wrong", http.StatusUnauthorized, "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.toggle != "" {
+				req := httptest.NewRequest(http.MethodPost, tt.toggle, nil)
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, req)
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("toggle status = %d, want %d", rec.Code, tt.wantStatus)
+				}
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/checkout", nil))
+			if rec.Body.String() != tt.want {
+				t.Errorf("handler served %q, want %q", rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFeatureFlagsSnapshot(t *testing.T) {
+	t.Cleanup(func() {
+		flagsMu.Lock()
+		delete(flags, "beta")
+		flagsMu.Unlock()
+	})
+	SetFlag("beta", true)
+	var before, after bool
+	h := FeatureFlags(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		before = FlagEnabled(r.Context(), "beta")
+		SetFlag("beta", false)
+		after = FlagEnabled(r.Context(), "beta")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !before || !after {
+		t.Errorf("flag during request = %v then %v, want it to stay on", before, after)
+	}
+}
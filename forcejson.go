@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+)
+
+// ForceJSON is for JSON API groups: every response is sent as
+// application/json with X-Content-Type-Options: nosniff, whatever type the
+// handler set, so that a response can never be taken for HTML by a browser.
+// Unlike DefaultContentType it overrides types handlers set themselves.
+func ForceJSON(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		jw := &jsonOnlyWriter{ResponseWriter: w}
+		next.ServeHTTP(jw, r)
+		if !jw.wroteHeader {
+			jw.setHeaders()
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+type jsonOnlyWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (jw *jsonOnlyWriter) WriteHeader(code int) {
+	if !jw.wroteHeader {
+		jw.wroteHeader = true
+		jw.setHeaders()
+	}
+	jw.ResponseWriter.WriteHeader(code)
+}
+
+func (jw *jsonOnlyWriter) setHeaders() {
+	jw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	jw.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+func (jw *jsonOnlyWriter) Write(p []byte) (int, error) {
+	if !jw.wroteHeader {
+		jw.WriteHeader(http.StatusOK)
+	}
+	return jw.ResponseWriter.Write(p)
+}
+
+func (jw *jsonOnlyWriter) Flush() {
+	if !jw.wroteHeader {
+		jw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := jw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (jw *jsonOnlyWriter) Unwrap() http.ResponseWriter {
+	return jw.ResponseWriter
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestForceJSON(t *testing.T) {
+	r := chi.NewRouter()
+	html := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<script>alert(1)</script>"))
+	}
+	r.Route("/api", func(r chi.Router) {
+		r.Use(ForceJSON)
+		r.Get("/html", html)
+		r.Get("/sniffed", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html></html>"))
+		})
+		r.Get("/status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusAccepted)
+		})
+		r.Get("/empty", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+		})
+		r.Get("/json", func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, r, http.StatusTeapot, "short and stout")
+		})
+	})
+	r.Get("/page", html)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantType    string
+		wantNoSniff bool
+	}{
+		{"HTML overridden", "/api/html", http.StatusOK, "application/json; charset=utf-8", true},
+		{"sniffing prevented", "/api/sniffed", http.StatusOK, "application/json; charset=utf-8", true},
+		{"explicit status", "/api/status", http.StatusAccepted, "application/json; charset=utf-8", true},
+		{"nothing written", "/api/empty", http.StatusOK, "application/json; charset=utf-8", true},
+		{"already JSON", "/api/json", http.StatusTeapot, "application/json; charset=utf-8", true},
+		{"outside the group", "/page", http.StatusOK, "text/html", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Header().Get("X-Content-Type-Options") == "nosniff"; got != tt.wantNoSniff {
+				t.Errorf("nosniff = %v, want %v", got, tt.wantNoSniff)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// healthPaths are always served, even while the server is not ready.
+var healthPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+func isHealthPath(r *http.Request) bool {
+	return healthPaths[r.URL.Path]
+}
+
+var ready atomic.Bool
+
+// MarkReady ends the warmup period early.
+func MarkReady() {
+	ready.Store(true)
+}
+
+// IsReady reports whether the warmup period is over.
+func IsReady() bool {
+	return ready.Load()
+}
+
+// Warmup rejects all non-health requests with a 503 until d has elapsed or
+// MarkReady is called, giving dependencies time to come up after a deploy.
+func Warmup(d time.Duration) func(next http.Handler) http.Handler {
+	time.AfterFunc(d, MarkReady)
+	readyAt := time.Now().Add(d)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !IsReady() && !isHealthPath(r) {
+				retryAfter := max(1, int(time.Until(readyAt).Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeLimitError(w, r, http.StatusServiceUnavailable, "warming up")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// readinessCheckTimeout bounds one run of all the readiness checks.
+const readinessCheckTimeout = 5 * time.Second
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var (
+	readinessMu     sync.Mutex
+	readinessChecks []readinessCheck
+)
+
+// RegisterReadinessCheck adds a dependency check, e.g. pinging the
+// database, that must pass for /readyz to report ready and, with
+// GateOnReadiness, for the server to take traffic.
+func RegisterReadinessCheck(name string, check func(ctx context.Context) error) {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+	readinessChecks = append(readinessChecks, readinessCheck{name: name, check: check})
+}
+
+// checkReadiness runs the registered checks in order and returns the first
+// failure.
+func checkReadiness(ctx context.Context) error {
+	readinessMu.Lock()
+	checks := readinessChecks
+	readinessMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// GateOnReadiness rejects all non-health requests with a 503 while any
+// readiness check is failing, so the server sheds load by itself when a
+// dependency goes down and picks it up again once it recovers. The checks
+// are re-run in the background at most once per interval; requests are
+// gated on the latest result and never wait for the checks.
+func GateOnReadiness(interval time.Duration) func(next http.Handler) http.Handler {
+	var (
+		mu         sync.Mutex
+		lastErr    error
+		checkedAt  time.Time
+		refreshing bool
+	)
+	refresh := func() {
+		err := checkReadiness(context.Background())
+		mu.Lock()
+		defer mu.Unlock()
+		if (err == nil) != (lastErr == nil) {
+			if err != nil {
+				log.Printf("readiness check failing, shedding traffic: %v", err)
+			} else {
+				log.Printf("readiness checks passing again")
+			}
+		}
+		lastErr = err
+		checkedAt = time.Now()
+		refreshing = false
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			if !refreshing && time.Since(checkedAt) >= interval {
+				refreshing = true
+				go refresh()
+			}
+			err := lastErr
+			mu.Unlock()
+
+			if err != nil && !isHealthPath(r) {
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(interval.Seconds()))))
+				writeLimitError(w, r, http.StatusServiceUnavailable, "service unavailable")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// healthHandler reports that the process is up.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// readyHandler reports whether the server is ready to take traffic.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if !IsReady() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("warming up"))
+		return
+	}
+	if Draining() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("draining"))
+		return
+	}
+	if err := checkReadiness(r.Context()); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestWarmup(t *testing.T) {
+	ready.Store(false)
+	t.Cleanup(func() { ready.Store(false) })
+	h := Warmup(50 * time.Millisecond)(okHandler)
+
+	tests := []struct {
+		name       string
+		path       string
+		wait       time.Duration
+		wantStatus int
+	}{
+		{"rejected while warming up", "/", 0, http.StatusServiceUnavailable},
+		{"health checks pass while warming up", "/healthz", 0, http.StatusOK},
+		{"served once ready", "/", 100 * time.Millisecond, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("503 without Retry-After")
+			}
+		})
+	}
+}
+
+func TestGateOnReadiness(t *testing.T) {
+	captureLog(t)
+	readinessMu.Lock()
+	oldChecks := readinessChecks
+	readinessChecks = nil
+	readinessMu.Unlock()
+	t.Cleanup(func() {
+		readinessMu.Lock()
+		readinessChecks = oldChecks
+		readinessMu.Unlock()
+	})
+
+	var (
+		down  atomic.Bool
+		calls atomic.Int32
+	)
+	RegisterReadinessCheck("db", func(ctx context.Context) error {
+		calls.Add(1)
+		if down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	const interval = 30 * time.Millisecond
+	h := GateOnReadiness(interval)(okHandler)
+
+	tests := []struct {
+		name       string
+		down       bool
+		path       string
+		wantStatus int
+	}{
+		{"healthy", false, "/", http.StatusOK},
+		{"dependency down", true, "/", http.StatusServiceUnavailable},
+		{"health checks still served", true, "/healthz", http.StatusOK},
+		{"recovered", false, "/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			down.Store(tt.down)
+			// The first request after the interval starts a background
+			// check; later ones see its result.
+			time.Sleep(interval)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			time.Sleep(10 * time.Millisecond)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Error("503 without Retry-After")
+			}
+		})
+	}
+
+	before := calls.Load()
+	for i := 0; i < 50; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load() - before; n > 1 {
+		t.Errorf("checks ran %d times for a burst of requests, want at most once per interval", n)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HopByHopHeaders are the headers RFC 7230 section 6.1 defines as meaningful
+// for a single connection only, which StripHopByHop removes from responses.
+// Trailer isn't among them: it is how a handler announces trailers, and
+// httputil.ReverseProxy relays an upstream's trailers by announcing them
+// again, so removing it would drop them.
+var HopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"TE",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// StripHopByHop removes HopByHopHeaders, and any header the response's own
+// Connection header names, before the response is sent, so that
+// connection-level headers set by a handler or an upstream don't leak to
+// the client. Informational responses such as 101 Switching Protocols are
+// left alone, since an upgrade needs Connection and Upgrade.
+func StripHopByHop(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		hw := &hopByHopWriter{ResponseWriter: w}
+		next.ServeHTTP(hw, r)
+		if !hw.wroteHeader {
+			hw.strip()
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+type hopByHopWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (hw *hopByHopWriter) WriteHeader(code int) {
+	if !hw.wroteHeader && code >= 200 {
+		hw.wroteHeader = true
+		hw.strip()
+	}
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+// strip removes the hop-by-hop headers from the response.
+func (hw *hopByHopWriter) strip() {
+	h := hw.Header()
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range HopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+func (hw *hopByHopWriter) Write(p []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	return hw.ResponseWriter.Write(p)
+}
+
+func (hw *hopByHopWriter) Flush() {
+	if !hw.wroteHeader {
+		hw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (hw *hopByHopWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
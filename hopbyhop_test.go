@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestStripHopByHop(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		noWrite bool
+		header  http.Header
+		want    http.Header
+	}{
+		{
+			name: "standard hop-by-hop headers",
+			header: http.Header{
+				"Connection":        {"keep-alive"},
+				"Keep-Alive":        {"timeout=5"},
+				"Transfer-Encoding": {"chunked"},
+				"Upgrade":           {"h2c"},
+				"Proxy-Connection":  {"keep-alive"},
+				"Content-Type":      {"text/plain"},
+			},
+			want: http.Header{"Content-Type": {"text/plain"}},
+		},
+		{
+			name: "headers named by Connection",
+			header: http.Header{
+				"Connection":       {"X-Upstream-Debug, X-Trace", "close"},
+				"X-Upstream-Debug": {"1"},
+				"X-Trace":          {"abc"},
+				"X-Request-Id":     {"r1"},
+			},
+			want: http.Header{"X-Request-Id": {"r1"}},
+		},
+		{
+			name:    "handler that writes nothing",
+			noWrite: true,
+			header:  http.Header{"Connection": {"close"}, "Keep-Alive": {"timeout=5"}, "X-Request-Id": {"r1"}},
+			want:    http.Header{"X-Request-Id": {"r1"}},
+		},
+		{
+			name:   "trailer announcement kept",
+			header: http.Header{"Trailer": {"Grpc-Status"}, "Content-Type": {"application/grpc"}},
+			want:   http.Header{"Trailer": {"Grpc-Status"}, "Content-Type": {"application/grpc"}},
+		},
+		{
+			name:   "switching protocols untouched",
+			status: http.StatusSwitchingProtocols,
+			header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+			want:   http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := StripHopByHop(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				if tt.noWrite {
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			got := rec.Result().Header
+			if tt.noWrite {
+				// The recorder only snapshots headers on a write; the server
+				// sends whatever is left in the map.
+				got = rec.Header()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripHopByHopKeepsProxiedTrailers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("ok"))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(StripHopByHop(httputil.NewSingleHostReverseProxy(target)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	io.ReadAll(resp.Body)
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("Grpc-Status trailer = %q, want 0", got)
+	}
+}
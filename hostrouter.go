@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type subdomainKey struct{}
+
+// HostRouter dispatches requests to a chi.Router chosen by the request host.
+// Hosts are either exact ("api.example.com") or a wildcard covering one or
+// more subdomain labels ("*.example.com").
+type HostRouter struct {
+	hosts     map[string]chi.Router
+	wildcards map[string]chi.Router
+
+	// Default serves requests whose host matches nothing. When nil, those
+	// requests get a 404.
+	Default http.Handler
+}
+
+// NewHostRouter returns an empty HostRouter.
+func NewHostRouter() *HostRouter {
+	return &HostRouter{
+		hosts:     make(map[string]chi.Router),
+		wildcards: make(map[string]chi.Router),
+	}
+}
+
+// Map routes requests for host to r.
+func (hr *HostRouter) Map(host string, r chi.Router) {
+	host = strings.ToLower(host)
+	if suffix, ok := strings.CutPrefix(host, "*"); ok {
+		hr.wildcards[suffix] = r
+		return
+	}
+	hr.hosts[host] = r
+}
+
+func (hr *HostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := stripPort(strings.ToLower(r.Host))
+
+	if router, ok := hr.hosts[host]; ok {
+		router.ServeHTTP(w, r)
+		return
+	}
+
+	// Prefer the most specific wildcard, i.e. the longest matching suffix.
+	var match string
+	for suffix := range hr.wildcards {
+		if strings.HasSuffix(host, suffix) && len(host) > len(suffix) && len(suffix) > len(match) {
+			match = suffix
+		}
+	}
+	if match != "" {
+		sub := strings.TrimSuffix(host, match)
+		ctx := context.WithValue(r.Context(), subdomainKey{}, sub)
+		hr.wildcards[match].ServeHTTP(w, r.WithContext(ctx))
+		return
+	}
+
+	if hr.Default != nil {
+		hr.Default.ServeHTTP(w, r)
+		return
+	}
+	writeError(w, r, http.StatusNotFound, "unknown host")
+}
+
+// Subdomain returns the part of the host matched by a wildcard in HostRouter,
+// e.g. "tenant1" for "tenant1.example.com" under "*.example.com".
+func Subdomain(ctx context.Context) string {
+	sub, _ := ctx.Value(subdomainKey{}).(string)
+	return sub
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
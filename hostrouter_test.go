@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHostRouter(t *testing.T) {
+	named := func(name string) chi.Router {
+		r := chi.NewRouter()
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + ":" + Subdomain(r.Context())))
+		})
+		return r
+	}
+	hr := NewHostRouter()
+	hr.Map("api.example.com", named("api"))
+	hr.Map("*.example.com", named("tenant"))
+	hr.Map("*.eu.example.com", named("eu"))
+
+	withDefault := NewHostRouter()
+	withDefault.Map("api.example.com", named("api"))
+	withDefault.Default = named("default")
+
+	tests := []struct {
+		name       string
+		hr         *HostRouter
+		host       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"exact", hr, "api.example.com", http.StatusOK, "api:"},
+		{"exact with port and case", hr, "API.example.com:8080", http.StatusOK, "api:"},
+		{"wildcard", hr, "acme.example.com", http.StatusOK, "tenant:acme"},
+		{"multi-label wildcard", hr, "a.b.example.com", http.StatusOK, "tenant:a.b"},
+		{"most specific wildcard", hr, "acme.eu.example.com", http.StatusOK, "eu:acme"},
+		{"bare domain is not a wildcard match", hr, "example.com", http.StatusNotFound, ""},
+		{"unknown host", hr, "other.org", http.StatusNotFound, ""},
+		{"fallback", withDefault, "other.org", http.StatusOK, "default:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			tt.hr.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/render"
+)
+
+var (
+	inflightRequests atomic.Int64
+	draining         atomic.Bool
+)
+
+// InFlight counts the requests currently being served.
+func InFlight(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		inflightRequests.Add(1)
+		defer inflightRequests.Add(-1)
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// InFlightCount returns the number of requests currently being served.
+func InFlightCount() int64 {
+	return inflightRequests.Load()
+}
+
+// Draining reports whether shutdown has begun.
+func Draining() bool {
+	return draining.Load()
+}
+
+// inflightHandler reports the in-flight request count and whether the server
+// is draining, to help operators decide whether to extend the drain timeout.
+func inflightHandler(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, map[string]any{
+		"inflight": InFlightCount(),
+		"draining": Draining(),
+	})
+}
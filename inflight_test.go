@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInflightHandler(t *testing.T) {
+	t.Cleanup(func() { draining.Store(false) })
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := InFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	tests := []struct {
+		name         string
+		active       int
+		draining     bool
+		wantInflight int64
+	}{
+		{"idle", 0, false, 0},
+		{"one active", 1, false, 1},
+		{"two active while draining", 2, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			for i := 0; i < tt.active; i++ {
+				go func() {
+					slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+					done <- struct{}{}
+				}()
+				<-started
+			}
+			draining.Store(tt.draining)
+
+			rec := httptest.NewRecorder()
+			inflightHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/inflight", nil))
+			var got struct {
+				Inflight int64 `json:"inflight"`
+				Draining bool  `json:"draining"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Inflight != tt.wantInflight || got.Draining != tt.draining {
+				t.Errorf("got %+v, want inflight %d, draining %v", got, tt.wantInflight, tt.draining)
+			}
+
+			for i := 0; i < tt.active; i++ {
+				release <- struct{}{}
+				<-done
+			}
+			if n := InFlightCount(); n != 0 {
+				t.Errorf("InFlightCount after requests finished = %d, want 0", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// latencySamples is how many durations are kept per route. Beyond that,
+// reservoir sampling keeps a uniform sample of all requests, so memory stays
+// bounded however much traffic a route gets.
+const latencySamples = 1024
+
+// maxLatencyRoutes caps how many routes are tracked; requests to any route
+// beyond that are recorded under latencyOverflow.
+const maxLatencyRoutes = 256
+
+const (
+	latencyUnmatched = "(unmatched)"
+	latencyOverflow  = "(other)"
+)
+
+type routeLatency struct {
+	count   int64
+	samples []time.Duration
+}
+
+type latencyRecorder struct {
+	mu     sync.Mutex
+	routes map[string]*routeLatency
+}
+
+var latencies = &latencyRecorder{routes: make(map[string]*routeLatency)}
+
+func (lr *latencyRecorder) record(route string, d time.Duration) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	rl, ok := lr.routes[route]
+	if !ok && len(lr.routes) >= maxLatencyRoutes {
+		route = latencyOverflow
+		rl, ok = lr.routes[route]
+	}
+	if !ok {
+		rl = &routeLatency{}
+		lr.routes[route] = rl
+	}
+	rl.count++
+	if len(rl.samples) < latencySamples {
+		rl.samples = append(rl.samples, d)
+		return
+	}
+	if i := rand.Int63n(rl.count); i < latencySamples {
+		rl.samples[i] = d
+	}
+}
+
+// RecordLatency feeds each request's duration into a per-route sample that
+// /debug/latency reports percentiles from. Routes are labelled by method and
+// HandlerName, i.e. the name given with Named or else the route pattern.
+// Requests that matched no route share a single entry whatever their
+// method, since both path and method are up to the client.
+func RecordLatency(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		r = withHandlerName(r)
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		route := latencyUnmatched
+		if name := HandlerName(r); name != "" {
+			route = r.Method + " " + name
+		}
+		latencies.record(route, time.Since(start))
+	}
+	return http.HandlerFunc(fn)
+}
+
+type latencySummary struct {
+	Count int64   `json:"count"`
+	P50MS float64 `json:"p50_ms"`
+	P95MS float64 `json:"p95_ms"`
+	P99MS float64 `json:"p99_ms"`
+}
+
+// latencyHandler reports p50, p95 and p99 latencies for every route seen.
+func latencyHandler(w http.ResponseWriter, r *http.Request) {
+	latencies.mu.Lock()
+	summaries := make(map[string]latencySummary, len(latencies.routes))
+	for route, rl := range latencies.routes {
+		sorted := append([]time.Duration(nil), rl.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		summaries[route] = latencySummary{
+			Count: rl.count,
+			P50MS: percentile(sorted, 0.50),
+			P95MS: percentile(sorted, 0.95),
+			P99MS: percentile(sorted, 0.99),
+		}
+	}
+	latencies.mu.Unlock()
+	render.JSON(w, r, summaries)
+}
+
+// percentile returns the p-th percentile of sorted, in milliseconds, using
+// the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	i = min(max(i, 0), len(sorted)-1)
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRecordLatency(t *testing.T) {
+	old := latencies
+	latencies = &latencyRecorder{routes: make(map[string]*routeLatency)}
+	t.Cleanup(func() { latencies = old })
+
+	r := chi.NewRouter()
+	r.Use(RecordLatency)
+	r.Get("/users/{id}", okHandler)
+	r.Method(http.MethodGet, "/avatars/{id}", Named("avatar", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}))
+
+	tests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodGet, "/avatars/1"},
+		{http.MethodGet, "/missing"},
+		{"BREW", "/missing"},
+		{"PROPFIND", "/elsewhere"},
+	}
+	for _, tt := range tests {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+	}
+
+	want := map[string]int64{
+		"GET /users/{id}": 2,
+		"GET avatar":      1,
+		latencyUnmatched:  3,
+	}
+	if len(latencies.routes) != len(want) {
+		t.Errorf("routes = %v, want %v", keys(latencies.routes), keys(want))
+	}
+	for route, count := range want {
+		if rl := latencies.routes[route]; rl == nil || rl.count != count {
+			t.Errorf("%q recorded %v, want count %d", route, rl, count)
+		}
+	}
+}
+
+func TestLatencyRouteCap(t *testing.T) {
+	lr := &latencyRecorder{routes: make(map[string]*routeLatency)}
+	for i := 0; i < maxLatencyRoutes+10; i++ {
+		lr.record(fmt.Sprintf("GET /r%d", i), time.Millisecond)
+	}
+	if got := len(lr.routes); got != maxLatencyRoutes+1 {
+		t.Errorf("tracked %d routes, want %d", got, maxLatencyRoutes+1)
+	}
+	if rl := lr.routes[latencyOverflow]; rl == nil || rl.count != 10 {
+		t.Errorf("overflow entry = %v, want count 10", rl)
+	}
+	lr.record("GET /r0", time.Millisecond)
+	if rl := lr.routes["GET /r0"]; rl.count != 2 {
+		t.Errorf("existing route count = %d, want 2", rl.count)
+	}
+}
+
+func keys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
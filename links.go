@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type linksKey struct{}
+
+type linkSet struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// Links adds relations such as "self", "collection" or "related" to the
+// Link header of r's response, e.g.
+//
+//	Links(r, map[string]string{"self": "/users/" + id, "collection": "/users"})
+//
+// Relative URLs are made absolute using the scheme and host the client
+// reached us on. Relations are emitted in alphabetical order. It does
+// nothing unless LinkHeaders is in the chain.
+func Links(r *http.Request, rels map[string]string) {
+	ls, ok := r.Context().Value(linksKey{}).(*linkSet)
+	if !ok {
+		return
+	}
+	base := &url.URL{Scheme: requestScheme(r), Host: r.Host, Path: "/"}
+
+	names := make([]string, 0, len(rels))
+	for rel := range rels {
+		names = append(names, rel)
+	}
+	sort.Strings(names)
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for _, rel := range names {
+		u, err := url.Parse(rels[rel])
+		if err != nil {
+			continue
+		}
+		ls.entries = append(ls.entries, fmt.Sprintf("<%s>; rel=%q", base.ResolveReference(u), rel))
+	}
+}
+
+// LinkHeaders writes the relations handlers add with Links into a single
+// Link header, just before the response headers are sent. Link headers
+// already set, by the handler or by middleware such as Deprecated, are kept
+// alongside it.
+func LinkHeaders(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ls := &linkSet{}
+		lw := &linkWriter{ResponseWriter: w, links: ls}
+		ctx := context.WithValue(r.Context(), linksKey{}, ls)
+		next.ServeHTTP(lw, r.WithContext(ctx))
+		if !lw.wroteHeader {
+			lw.addHeader()
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+type linkWriter struct {
+	http.ResponseWriter
+	links       *linkSet
+	wroteHeader bool
+}
+
+func (lw *linkWriter) WriteHeader(code int) {
+	if !lw.wroteHeader {
+		lw.wroteHeader = true
+		lw.addHeader()
+	}
+	lw.ResponseWriter.WriteHeader(code)
+}
+
+// addHeader adds the relations collected so far as one Link header.
+func (lw *linkWriter) addHeader() {
+	lw.links.mu.Lock()
+	entries := lw.links.entries
+	lw.links.mu.Unlock()
+	if len(entries) > 0 {
+		lw.Header().Add("Link", strings.Join(entries, ", "))
+	}
+}
+
+func (lw *linkWriter) Write(p []byte) (int, error) {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	return lw.ResponseWriter.Write(p)
+}
+
+func (lw *linkWriter) Flush() {
+	if !lw.wroteHeader {
+		lw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := lw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (lw *linkWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
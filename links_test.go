@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLinkHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		deprecated bool
+		own        string
+		rels       map[string]string
+		tls        bool
+		noBody     bool
+		want       []string
+	}{
+		{
+			name: "relations comma-joined in order",
+			rels: map[string]string{"self": "/users/1", "collection": "/users", "related": "https://docs.example.com/users"},
+			want: []string{`<http://api.example.com/users>; rel="collection", <https://docs.example.com/users>; rel="related", <http://api.example.com/users/1>; rel="self"`},
+		},
+		{
+			name: "scheme from TLS",
+			rels: map[string]string{"self": "/users/1"},
+			tls:  true,
+			want: []string{`<https://api.example.com/users/1>; rel="self"`},
+		},
+		{
+			name:   "response with no body",
+			rels:   map[string]string{"self": "/users/1"},
+			noBody: true,
+			want:   []string{`<http://api.example.com/users/1>; rel="self"`},
+		},
+		{
+			name: "no relations",
+		},
+		{
+			name:       "kept alongside other Link headers",
+			deprecated: true,
+			own:        `</users?page=2>; rel="next"`,
+			rels:       map[string]string{"self": "/users"},
+			want: []string{
+				`<https://docs.example.com/migrate>; rel="deprecation"`,
+				`</users?page=2>; rel="next"`,
+				`<http://api.example.com/users>; rel="self"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.own != "" {
+					w.Header().Add("Link", tt.own)
+				}
+				Links(r, tt.rels)
+				if !tt.noBody {
+					w.Write([]byte("{}"))
+				}
+			})
+			h = LinkHeaders(h)
+			if tt.deprecated {
+				h = Deprecated(time.Now().Add(time.Hour), "https://docs.example.com/migrate")(h)
+			}
+			target := "http://api.example.com/users/1"
+			if tt.tls {
+				target = "https://api.example.com/users/1"
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Values("Link"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type localeKey struct{}
+
+// Locale picks the best of the supported locales for the request's
+// Accept-Language header and stores it in the context for
+// LocaleFromContext. Language ranges are tried in quality order and matched
+// with RFC 4647 basic filtering, so "en" matches a supported "en-GB". When
+// nothing matches, fallback is used.
+func Locale(supported []string, fallback string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			locale := matchLocale(r.Header.Get("Accept-Language"), supported, fallback)
+			ctx := context.WithValue(r.Context(), localeKey{}, locale)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// LocaleFromContext returns the locale chosen by the Locale middleware.
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
+
+type languageRange struct {
+	tag string
+	q   float64
+}
+
+func matchLocale(header string, supported []string, fallback string) string {
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		if q := quality(params); q > 0 {
+			ranges = append(ranges, languageRange{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, lr := range ranges {
+		for _, s := range supported {
+			if basicFilter(lr.tag, s) {
+				return s
+			}
+		}
+	}
+	return fallback
+}
+
+// basicFilter reports whether the language range matches tag under RFC 4647
+// basic filtering: the range equals the tag or is a prefix of it ending at a
+// subtag boundary, ignoring case. "*" matches everything.
+func basicFilter(languageRange, tag string) bool {
+	if languageRange == "*" {
+		return true
+	}
+	if len(tag) < len(languageRange) || !strings.EqualFold(tag[:len(languageRange)], languageRange) {
+		return false
+	}
+	return len(tag) == len(languageRange) || tag[len(languageRange)] == '-'
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	h := Locale([]string{"en-GB", "fr", "de-CH"}, "en-GB")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(LocaleFromContext(r.Context())))
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"no header falls back", "", "en-GB"},
+		{"exact match", "fr", "fr"},
+		{"case-insensitive", "FR", "fr"},
+		{"prefix matches a region", "de", "de-CH"},
+		{"q-values order the ranges", "fr;q=0.5, de;q=0.9", "de-CH"},
+		{"unlisted q means 1", "fr;q=0.9, de", "de-CH"},
+		{"equal q keeps header order", "fr;q=0.8, de;q=0.8", "fr"},
+		{"q=0 excludes a range", "fr;q=0, de-CH;q=0.1", "de-CH"},
+		{"unsupported falls back", "ja, zh", "en-GB"},
+		{"region does not match a bare language", "fr-CA", "en-GB"},
+		{"wildcard takes the first supported", "ja, *;q=0.1", "en-GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("locale = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type handlerNameKey struct{}
+
+// Named attaches a human-readable name to h so that logs and metrics can
+// tell apart handlers which share a route pattern, e.g. across sub-routers.
+func Named(name string, h Handler) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if slot, ok := r.Context().Value(handlerNameKey{}).(*string); ok {
+			*slot = name
+		}
+		return h(w, r)
+	}
+}
+
+// HandlerName returns the name given to the handler serving r with Named,
+// falling back to the matched route pattern. It is only reliable once the
+// handler has run.
+func HandlerName(r *http.Request) string {
+	if slot, ok := r.Context().Value(handlerNameKey{}).(*string); ok && *slot != "" {
+		return *slot
+	}
+	return routePattern(r)
+}
+
+// routePattern returns the pattern of the route that matched r, or "" if
+// none did. Unlike chi's RoutePattern it reports the root route as "/".
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || len(rctx.RoutePatterns) == 0 {
+		return ""
+	}
+	if p := rctx.RoutePattern(); p != "" {
+		return p
+	}
+	return "/"
+}
+
+// withHandlerName makes room in the request context for Named to record the
+// handler name, so middleware running outside the handler can read it back.
+func withHandlerName(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(handlerNameKey{}).(*string); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), handlerNameKey{}, new(string)))
+}
+
+// AccessLogger is middleware.Logger's plain text access log, written to out,
+// with the handler name from HandlerName added to the end of each line.
+// Lines are colored if color is set, as middleware.Logger does everywhere
+// but on Windows.
+func AccessLogger(out io.Writer, color bool) func(next http.Handler) http.Handler {
+	f := &namedLogFormatter{logger: log.New(out, "", log.LstdFlags), noColor: !color}
+	return func(next http.Handler) http.Handler {
+		logged := middleware.RequestLogger(f)(next)
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			logged.ServeHTTP(w, withHandlerName(r))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// namedLogFormatter formats lines as middleware.DefaultLogFormatter does,
+// adding the handler name once the request has been served.
+type namedLogFormatter struct {
+	logger  middleware.LoggerInterface
+	noColor bool
+}
+
+func (f *namedLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	df := &middleware.DefaultLogFormatter{Logger: handlerNameLogger{f.logger, r}, NoColor: f.noColor}
+	return df.NewLogEntry(r)
+}
+
+// handlerNameLogger prints a log line with r's handler name appended.
+type handlerNameLogger struct {
+	logger middleware.LoggerInterface
+	r      *http.Request
+}
+
+func (hl handlerNameLogger) Print(v ...any) {
+	if name := HandlerName(hl.r); name != "" {
+		v = append(v, " handler=", name)
+	}
+	hl.logger.Print(v...)
+}
+
+type logFieldsKey struct{}
+
+// logFields is the bag of extra attributes handlers add to the request's log
+// line with LogField.
+type logFields struct {
+	mu     sync.Mutex
+	keys   []string
+	values map[string]any
+}
+
+// LogField adds key to the access log line of the request ctx belongs to,
+// e.g. whether a cache was hit. Setting a key again overwrites it. It does
+// nothing unless StructuredLogger is logging the request.
+func LogField(ctx context.Context, key string, value any) {
+	lf, ok := ctx.Value(logFieldsKey{}).(*logFields)
+	if !ok {
+		return
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if _, ok := lf.values[key]; !ok {
+		lf.keys = append(lf.keys, key)
+	}
+	lf.values[key] = value
+}
+
+// attrs returns the fields in the order they were first set.
+func (lf *logFields) attrs() []slog.Attr {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	attrs := make([]slog.Attr, 0, len(lf.keys))
+	for _, k := range lf.keys {
+		attrs = append(attrs, slog.Any(k, lf.values[k]))
+	}
+	return attrs
+}
+
+// StructuredLogger logs one structured line per request to logger, in place
+// of middleware.Logger's plain text output. Handlers can add to the line with
+// LogField, and requests picked by Sampler get extra request details.
+func StructuredLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			r = withHandlerName(r)
+			lf := &logFields{values: make(map[string]any)}
+			r = r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, lf))
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			defer func() {
+				status := ww.Status()
+				if clientClosed(r) {
+					status = StatusClientClosedRequest
+				}
+				attrs := []slog.Attr{
+					slog.String("request_id", RequestID(r)),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("handler", HandlerName(r)),
+					slog.Int("status", status),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.Duration("duration", time.Since(start)),
+				}
+				if status != ww.Status() {
+					attrs = append(attrs, slog.Int("written_status", ww.Status()))
+				}
+				for name, v := range BaggageFromContext(r.Context()) {
+					attrs = append(attrs, slog.String(name, v))
+				}
+				attrs = append(attrs, lf.attrs()...)
+				if IsTraced(r.Context()) {
+					attrs = append(attrs,
+						slog.Bool("traced", true),
+						slog.String("query", r.URL.RawQuery),
+						slog.String("remote_ip", clientIP(r)),
+						slog.String("user_agent", r.UserAgent()),
+						slog.Int64("request_bytes", r.ContentLength))
+				}
+				logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// StatusClientClosedRequest is nginx's non-standard status for a request the
+// client abandoned before the response was complete.
+const StatusClientClosedRequest = 499
+
+// clientClosed reports whether r was cancelled because the client went away.
+func clientClosed(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// LogClientClosed logs requests whose client disconnected mid-request with a
+// synthetic 499, alongside the status actually written, so client-caused
+// aborts don't show up as a misleading 200 in middleware.Logger output.
+func LogClientClosed(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if clientClosed(r) {
+			log.Printf("[%s] \"%s %s\" %d Client Closed Request (wrote status %d, %dB)",
+				RequestID(r), r.Method, r.URL.Path,
+				StatusClientClosedRequest, ww.Status(), ww.BytesWritten())
+		}
+	}
+	return http.HandlerFunc(fn)
+}
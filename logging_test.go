@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLogField(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    map[string]any
+	}{
+		{
+			name: "fields set by handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				LogField(r.Context(), "user_id", "u-42")
+				LogField(r.Context(), "cache", "hit")
+			},
+			want: map[string]any{"user_id": "u-42", "cache": "hit"},
+		},
+		{
+			name: "overwritten",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				LogField(r.Context(), "cache", "miss")
+				LogField(r.Context(), "cache", "hit")
+			},
+			want: map[string]any{"cache": "hit"},
+		},
+		{
+			name: "set concurrently",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var wg sync.WaitGroup
+				for _, k := range []string{"a", "b", "c"} {
+					wg.Add(1)
+					go func(k string) {
+						defer wg.Done()
+						LogField(r.Context(), k, 1)
+					}(k)
+				}
+				wg.Wait()
+			},
+			want: map[string]any{"a": 1.0, "b": 1.0, "c": 1.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			StructuredLogger(logger)(tt.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			var line map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("log line %q: %v", buf.String(), err)
+			}
+			for k, v := range tt.want {
+				if line[k] != v {
+					t.Errorf("logged %s = %v, want %v", k, line[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestLogFieldWithoutLogger(t *testing.T) {
+	// Must not panic when StructuredLogger isn't in the chain.
+	LogField(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "k", "v")
+}
+
+func TestAccessLogger(t *testing.T) {
+	var buf bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(AccessLogger(&buf, false))
+	r.Method(http.MethodGet, "/picture", Named("picture", func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("img"))
+		return nil
+	}))
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		wantLine    string
+		wantHandler string
+	}{
+		{"named handler", "/picture", `"GET http://example.com/picture HTTP/1.1" from 192.0.2.1:1234 - 200 3B in `, "picture"},
+		{"route pattern", "/items/1", `"GET http://example.com/items/1 HTTP/1.1" from 192.0.2.1:1234 - 200 `, "/items/{id}"},
+		{"no route", "/nope", `"GET http://example.com/nope HTTP/1.1" from 192.0.2.1:1234 - 404 `, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+			line := strings.TrimSuffix(buf.String(), "\n")
+
+			if !strings.Contains(line, tt.wantLine) {
+				t.Errorf("log line %q, want it to contain %q", line, tt.wantLine)
+			}
+			_, handler, _ := strings.Cut(line, " handler=")
+			if handler != tt.wantHandler {
+				t.Errorf("logged handler %q, want %q", handler, tt.wantHandler)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// longPollInterval is how often LongPoll calls check.
+var longPollInterval = 100 * time.Millisecond
+
+// LongPoll calls check right away and then every longPollInterval until it
+// reports data, returning that data and true. It gives up, returning false,
+// once wait has passed or ctx is done, so that a handler can answer with a
+// 204 and let the client poll again.
+func LongPoll(ctx context.Context, wait time.Duration, check func() (any, bool)) (any, bool) {
+	if v, ok := check(); ok {
+		return v, true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(longPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-timer.C:
+			return nil, false
+		case <-ticker.C:
+			if v, ok := check(); ok {
+				return v, true
+			}
+		}
+	}
+}
+
+type notification struct {
+	Seq     int    `json:"seq"`
+	Message string `json:"message"`
+}
+
+// latestNotification is the last message posted to /poll.
+var latestNotification struct {
+	sync.Mutex
+	notification
+}
+
+// pollHandler is an example notification channel. GET /poll?since=N waits
+// up to 30 seconds for a message newer than sequence number N, answering
+// with a 204 if none comes; POST /poll?message=... posts one.
+func pollHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == http.MethodPost {
+		latestNotification.Lock()
+		latestNotification.Seq++
+		latestNotification.Message = r.URL.Query().Get("message")
+		n := latestNotification.notification
+		latestNotification.Unlock()
+		render.JSON(w, r, n)
+		return nil
+	}
+
+	since, err := strconv.Atoi(r.URL.Query().Get("since"))
+	if err != nil && r.URL.Query().Has("since") {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "since must be an integer"}
+	}
+	n, ok := LongPoll(r.Context(), 30*time.Second, func() (any, bool) {
+		latestNotification.Lock()
+		defer latestNotification.Unlock()
+		return latestNotification.notification, latestNotification.Seq > since
+	})
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	render.JSON(w, r, n)
+	return nil
+}
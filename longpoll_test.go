@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLongPoll(t *testing.T) {
+	oldInterval := longPollInterval
+	longPollInterval = 5 * time.Millisecond
+	t.Cleanup(func() { longPollInterval = oldInterval })
+
+	tests := []struct {
+		name      string
+		readyAt   int // the check call that first reports data; 0 for never
+		wait      time.Duration
+		cancelled bool
+		wantOK    bool
+		maxTook   time.Duration
+	}{
+		{"data already there", 1, time.Second, false, true, 50 * time.Millisecond},
+		{"data arrives while waiting", 4, time.Second, false, true, 500 * time.Millisecond},
+		{"timeout", 0, 50 * time.Millisecond, false, false, 500 * time.Millisecond},
+		{"client gone", 0, time.Second, true, false, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				time.AfterFunc(30*time.Millisecond, cancel)
+			}
+			calls := 0
+			start := time.Now()
+			v, ok := LongPoll(ctx, tt.wait, func() (any, bool) {
+				calls++
+				return "news", calls == tt.readyAt
+			})
+			took := time.Since(start)
+
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && v != "news" {
+				t.Errorf("data = %v, want news", v)
+			}
+			if !ok && v != nil {
+				t.Errorf("data = %v, want nil", v)
+			}
+			if !ok && !tt.cancelled && took < tt.wait {
+				t.Errorf("gave up after %s, want at least %s", took, tt.wait)
+			}
+			if took > tt.maxTook {
+				t.Errorf("took %s, want under %s", took, tt.maxTook)
+			}
+		})
+	}
+}
+
+func TestPollHandler(t *testing.T) {
+	oldInterval := longPollInterval
+	longPollInterval = 5 * time.Millisecond
+	latestNotification.Lock()
+	oldNotification := latestNotification.notification
+	latestNotification.notification = notification{}
+	latestNotification.Unlock()
+	t.Cleanup(func() {
+		longPollInterval = oldInterval
+		latestNotification.Lock()
+		latestNotification.notification = oldNotification
+		latestNotification.Unlock()
+	})
+	h := Handler(pollHandler)
+
+	// A waiting client gets the message posted while it waits.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/poll?since=0", nil))
+		done <- rec
+	}()
+	time.Sleep(20 * time.Millisecond)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/poll?message=hello", nil))
+
+	select {
+	case rec := <-done:
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"message":"hello"`) {
+			t.Errorf("waiting client got %d %q, want 200 with the message", rec.Code, rec.Body.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting client never got the message")
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"caught up", "since=1", http.StatusNoContent, ""},
+		{"behind", "since=0", http.StatusOK, `{"seq":1,"message":"hello"}`},
+		{"bad since", "since=x", http.StatusBadRequest, "since must be an integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The handler waits 30 seconds; a client hanging up after 50ms
+			// ends the wait the same way.
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/poll?"+tt.query, nil).WithContext(ctx))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"html/template"
+	"log"
+	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,23 +23,125 @@ type Handler func(w http.ResponseWriter, r *http.Request) error
 
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := h(w, r); err != nil {
+		// A client that disconnected mid-response is routine, and writing
+		// an error to the dead connection would only fail again.
+		if clientGone(r, err) {
+			slog.Debug("client went away", "request_id", RequestID(r), "err", err)
+			return
+		}
+
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			recordError(r, http.StatusUnprocessableEntity, err.Error())
+			writeValidationError(w, r, verr)
+			return
+		}
+		var herr *HTTPError
+		if errors.As(err, &herr) {
+			recordError(r, herr.Status, err.Error())
+			writeLimitError(w, r, herr.Status, herr.Message)
+			return
+		}
+
 		// handle returned error here.
+		recordError(r, 503, err.Error())
 		w.WriteHeader(503)
 		w.Write([]byte("bad"))
 	}
 }
 
+// HandleAll registers h for every HTTP method on pattern, e.g. for webhook
+// receivers that accept whatever the sender uses.
+func HandleAll(r chi.Router, pattern string, h Handler) {
+	r.Handle(pattern, h)
+}
+
 func main() {
 	r := chi.NewRouter()
+	cfg := LoadConfig()
 	//--
 	// This line adds the RequestID middleware to your router. The RequestID middleware generates a unique ID for each HTTP request. This is useful for logging and tracing requests through your system. If an ID is already present in the request header, it will use that, otherwise, it will generate a new one.
 	r.Use(middleware.RequestID)
 	//--
+	// InFlight counts the requests being served, which GET /admin/inflight reports while the server drains.
+	r.Use(InFlight)
+	//--
+	// Every response says which build (X-App-Version) and host (X-Served-By) served it, and how long it took (X-Request-Duration, in ms).
+	r.Use(BuildInfoHeaders)
+	//--
+	// HardenCookies gives every cookie set below it HttpOnly (except the CSRF token), SameSite=Lax and, over HTTPS, Secure.
+	r.Use(HardenCookies(DefaultCookiePolicy))
+	//--
+	// Baggage carries correlation headers into the request context, ahead of the logger so that they get logged.
+	r.Use(Baggage([]string{"X-Correlation-ID", "X-Tenant-ID"}))
+	//--
+	// Sampler marks TRACE_SAMPLE_RATE (e.g. 0.01) of requests as traced, which StructuredLogger logs in more detail.
+	r.Use(Sampler(cfg.TraceSampleRate))
+	//--
 	//Here, the Logger middleware is added to the router. This middleware logs the start and end of each request with the elapsed processing time, status code, and similar request details. It's useful for monitoring and debugging the behavior of your web application by providing insights into the traffic it's handling.
-	r.Use(middleware.Logger)
+	// AccessLogger is middleware.Logger with the handler name (see Named) added to each line.
+	// Setting LOG_FORMAT=json swaps it for StructuredLogger, which records the handler name too, and
+	// LOG_FORMAT=clf or combined for Apache-style Common or Combined Log Format lines for legacy tooling.
+	switch cfg.LogFormat {
+	case "json":
+		r.Use(StructuredLogger(slog.New(slog.NewJSONHandler(os.Stdout, nil))))
+	case "clf", "combined":
+		r.Use(CLFLogger(os.Stdout, cfg.LogFormat == "combined"))
+	default:
+		r.Use(AccessLogger(os.Stdout, runtime.GOOS != "windows"))
+		r.Use(LogClientClosed)
+	}
+	//--
+	// Setting TRACE_FILE records every request, with any spans handlers add through StartSpan, as JSON lines in that file.
+	if cfg.TraceFile != "" {
+		tw, err := NewTraceWriter(cfg.TraceFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		RegisterShutdownHook(func(context.Context) error { return tw.Close() })
+		r.Use(tw.Middleware)
+	}
+	//--
+	// AlertOnServerError sits outside the recoverer so that it also sees the 500s written for panics.
+	r.Use(AlertOnServerError)
+	//--
+	//This middleware recovers from panics anywhere in the chain, mounted sub-routers included, prevents the panic from crashing the server, and logs the panic with the route it happened on. The client gets the usual JSON error envelope with a 500.
+	r.Use(Recover)
+	//--
+	// BasicWAF turns away obvious attack probes (path traversal, script and SQL injection) with a 400.
+	r.Use(BasicWAF(DefaultWAFRules))
+	//--
+	// RedirectLoopGuard answers with a 508 once a client has been redirected 10 times in a row, e.g. by conflicting redirect rules.
+	r.Use(RedirectLoopGuard(10))
 	//--
-	//This middleware recovers from panics anywhere in the chain, prevents the panic from crashing the server, and logs the panic. This is a safety feature to ensure that if your application encounters an unexpected error during request processing, it can recover gracefully without crashing.
-	r.Use(middleware.Recoverer)
+	// Paths never end in a slash: GET and HEAD are redirected to the form without one, anything else gets a 404.
+	// The /files tree keeps http.FileServer's own directory redirects.
+	r.Use(TrailingSlash(SlashNever, "/files/"))
+	//--
+	// Warmup holds back everything but the health checks for WARMUP (e.g. "10s") after start, so dependencies have time to come up.
+	r.Use(Warmup(cfg.Warmup))
+	//--
+	// GateOnReadiness sheds non-health traffic with a 503 while a registered readiness check fails, re-checking every 5 seconds.
+	r.Use(GateOnReadiness(5 * time.Second))
+	//--
+	// With CHAOS_ENABLED=true, requests are delayed by up to CHAOS_LATENCY and fail with a 500 at CHAOS_ERROR_RATE (0 to 1).
+	r.Use(Chaos(cfg.ChaosLatency, cfg.ChaosErrorRate))
+	//--
+	// Maintenance serves a 503 page to everything but the health checks while switched on through POST /admin/maintenance.
+	r.Use(Maintenance)
+	//--
+	// FeatureFlags gives each request a consistent view of the flags toggled through POST /admin/flags/{name}.
+	r.Use(FeatureFlags)
+	//--
+	// Requests get REQUEST_TIMEOUT (default 60s) to answer, except on routes marked Streaming.
+	r.Use(Timeout(cfg.RequestTimeout))
+	//--
+	// With DEBUG=true, per-route latency percentiles are collected and reported at /debug/latency,
+	// and the first request and response of every route at /debug/examples.
+	if cfg.Debug {
+		r.Use(RecordLatency)
+		r.Use(RecordExamples)
+	}
 	// --
 	// w (of type http.ResponseWriter): This is used to write the response that will be sent back to the client. The ResponseWriter interface is used to send HTTP responses.
 	// r (of type *http.Request): This represents the HTTP request received by the server. It contains details like the request URL, headers, query parameters, etc.
@@ -43,16 +153,88 @@ func main() {
 		w.Write([]byte("hello world"))
 	})
 
+	Favicon(r, defaultFavicon)
+
+	// Pages are listed in /sitemap.xml with RegisterSitemapURL.
+	RegisterSitemapURL("/", ChangeFrequency("monthly"), Priority(1))
+	r.Get("/sitemap.xml", sitemapHandler)
+
+	// A minimal OpenAPI document of every route, plus whatever DescribeRoute adds.
+	r.Get("/openapi.json", openAPIHandler(r))
+
+	r.Get("/healthz", healthHandler)
+	r.Get("/readyz", readyHandler)
+
 	// Example of customHandler being used when a user hits the /picture endpoint.
-	r.Method("GET", "/picture", Handler(customHandler))
+	r.Method("GET", "/picture", Named("picture", customHandler))
+
+	// Example of a Server-Sent Events stream.
+	r.With(Streaming).Method("GET", "/events", Handler(eventsHandler))
+
+	// Example of a long-polled notification channel: GET /poll?since=N waits for news, POST /poll?message=... sends some.
+	r.Method("GET", "/poll", Handler(pollHandler))
+	r.Method("POST", "/poll", Handler(pollHandler))
+
+	// JSON-RPC 2.0 methods are all served from the single /rpc endpoint.
+	rpc := NewRPCServer()
+	rpc.Register("ping", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return "pong", nil
+	})
+	r.With(ForceJSON).Method("POST", "/rpc", Handler(rpc.ServeRPC))
+	DescribeRoute("POST", "/rpc", RouteMeta{Summary: "JSON-RPC 2.0 endpoint, single or batched calls"})
+
+	// Operator endpoints, all of which require the ADMIN_TOKEN bearer token.
+	r.Mount("/admin", adminRouter())
+
+	// Setting PROXY_UPSTREAM forwards everything under /proxy/ to that base URL.
+	if cfg.ProxyUpstream != "" {
+		ReverseProxy(r, "/proxy", cfg.ProxyUpstream)
+	}
+
+	// Introspection endpoints such as /debug/routes are only exposed when DEBUG=true.
+	if cfg.Debug {
+		r.Mount("/debug", debugRouter(r, cfg))
+	}
 
 	// Create a route along /files that will serve contents from
 	// the ./data/ folder.
 	workDir, _ := os.Getwd()
 	filesDir := http.Dir(filepath.Join(workDir, "data"))
-	FileServer(r, "/files", filesDir)
+	// Uploads land in there too, so treat every file as untrusted. Text files of 1KB or more are gzipped,
+	// and missing files get an HTML page.
+	FileServerWithOptions(r, "/files", filesDir, FileServerOptions{
+		Untrusted:        true,
+		GzipMinSize:      1 << 10,
+		NotFoundTemplate: filesNotFoundTemplate,
+	})
+	// Zipping the whole tree is expensive: at most 4 run at once, with 16 more queued in arrival order,
+	// and a download is cut off after 30 minutes.
+	r.With(Streaming, RouteThrottle(4, 16), MaxResponseDuration(30*time.Minute)).Method("GET", "/files.zip", ZipDir(filepath.Join(workDir, "data"), "files.zip"))
 
-	http.ListenAndServe(":3333", r)
+	// Uploads are streamed into ./data/uploads/, at most 32MB and 10 parts per request, and must finish within 10 minutes.
+	uploadDir := filepath.Join(workDir, "data", "uploads")
+	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	r.With(Streaming, EnforceMultipart(32<<20, 10)).Method("POST", "/upload", uploadHandler(uploadDir, 10*time.Minute))
+
+	// Pagination cursors are signed with CURSOR_SECRET, so they stay valid across restarts and replicas.
+	if cfg.CursorSecret != "" {
+		CursorSecret = []byte(cfg.CursorSecret)
+	}
+
+	// On SIGINT or SIGTERM, wait DRAIN_DELAY (e.g. "5s") for load balancers to notice /readyz failing,
+	// then give in-flight requests up to SHUTDOWN_TIMEOUT to finish.
+	// A supervisor can hand over an already-bound socket as fd 3 with LISTEN_FDS=1 for zero-downtime restarts.
+	DrainDelay = cfg.DrainDelay
+	ShutdownTimeout = cfg.ShutdownTimeout
+	// Catch middleware that depends on other middleware running first before serving anything.
+	validateMiddlewareOrder(r)
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: r}
+	if err := serve(srv); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // Example of a custom handler function.
@@ -67,9 +249,65 @@ func customHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// FileServerOptions tweaks how FileServerWithOptions serves files.
+type FileServerOptions struct {
+	// Untrusted is for serving user uploads: every file is sent with
+	// X-Content-Type-Options: nosniff, and files whose type isn't in
+	// SafeContentTypes are sent as UntrustedContentType, so that a disguised
+	// HTML or SVG file can't run script on our origin.
+	Untrusted bool
+
+	// UntrustedContentType defaults to "text/plain; charset=utf-8".
+	UntrustedContentType string
+
+	// GzipMinSize turns on gzip compression, for clients that accept it, of
+	// files at least this many bytes long. Zero leaves files uncompressed.
+	GzipMinSize int64
+
+	// GzipSkipTypes are the content types never compressed. It defaults to
+	// DefaultGzipSkipTypes.
+	GzipSkipTypes []string
+
+	// NotFoundTemplate, if set, renders the page for missing files instead
+	// of a plain-text 404. It is executed with the requested .Path and the
+	// .RequestID.
+	NotFoundTemplate *template.Template
+}
+
+// SafeContentTypes are the types served as-is in untrusted mode.
+var SafeContentTypes = map[string]bool{
+	"text/plain":      true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+var filesNotFoundTemplate = template.Must(template.New("404").Parse(`<!DOCTYPE html>
+<title>Not found</title>
+<h1>Not found</h1>
+<p>There is no file at {{.Path}}.</p>
+<p><small>Request ID: {{.RequestID}}</small></p>
+`))
+
 // FileServer conveniently sets up a http.FileServer handler to serve
-// static files from a http.FileSystem.
+// static files from a http.FileSystem. path is redirected to path+"/", and
+// http.FileServer redirects directories to their trailing-slash form and
+// files to the form without one, so exempt path+"/" from TrailingSlash.
 func FileServer(r chi.Router, path string, root http.FileSystem) {
+	FileServerWithOptions(r, path, root, FileServerOptions{})
+}
+
+// FileServerWithOptions is FileServer with extra options.
+func FileServerWithOptions(r chi.Router, path string, root http.FileSystem, opts FileServerOptions) {
+	if opts.UntrustedContentType == "" {
+		opts.UntrustedContentType = "text/plain; charset=utf-8"
+	}
+	if opts.GzipSkipTypes == nil {
+		opts.GzipSkipTypes = DefaultGzipSkipTypes
+	}
+
 	if strings.ContainsAny(path, "{}*") {
 		panic("FileServer does not permit any URL parameters.")
 	}
@@ -83,10 +321,44 @@ func FileServer(r chi.Router, path string, root http.FileSystem) {
 	r.Get(path, func(w http.ResponseWriter, r *http.Request) {
 		rctx := chi.RouteContext(r.Context())
 		pathPrefix := strings.TrimSuffix(rctx.RoutePattern(), "/*")
+		if opts.Untrusted {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			// Directory listings are generated by http.FileServer, not uploaded.
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				w.Header().Set("Content-Type", untrustedContentType(r.URL.Path, opts.UntrustedContentType))
+			}
+		}
 		fs := http.StripPrefix(pathPrefix, http.FileServer(root))
-		fs.ServeHTTP(w, r)
+		if opts.GzipMinSize > 0 {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		if opts.GzipMinSize > 0 && acceptsGzip(r) {
+			gw := &gzipFileWriter{ResponseWriter: w, minSize: opts.GzipMinSize, skip: opts.GzipSkipTypes}
+			defer gw.Close()
+			w = gw
+		}
+		if opts.NotFoundTemplate == nil {
+			fs.ServeHTTP(w, r)
+			return
+		}
+		nw := &notFoundWriter{ResponseWriter: w}
+		fs.ServeHTTP(nw, r)
+		if nw.notFound {
+			serveNotFoundPage(w, r, opts.NotFoundTemplate)
+		}
 	})
 }
 
+// untrustedContentType returns the type to serve an untrusted file as: its
+// usual type when that is safe, and fallback otherwise.
+func untrustedContentType(name, fallback string) string {
+	ct := mime.TypeByExtension(filepath.Ext(name))
+	mediaType, _, _ := strings.Cut(ct, ";")
+	if SafeContentTypes[mediaType] {
+		return ct
+	}
+	return fallback
+}
+
 //Notes:
 //1. You can create your own custom HTTP Methods(i.e GET, POST...), however be aware that when creating a custom
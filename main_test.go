@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// brokenPipeWriter fails every write as if the client had hung up.
+type brokenPipeWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (bw *brokenPipeWriter) Write(p []byte) (int, error) {
+	bw.writes++
+	return 0, fmt.Errorf("write tcp: %w", syscall.EPIPE)
+}
+
+func TestHandlerClientGone(t *testing.T) {
+	tests := []struct {
+		name       string
+		handlerErr error
+		cancelled  bool
+		wantWrites int
+		wantStatus int
+	}{
+		{"broken pipe", nil, false, 1, http.StatusOK},
+		{"request cancelled", context.Canceled, true, 0, http.StatusOK},
+		{"inner call cancelled", fmt.Errorf("query: %w", context.Canceled), false, 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				if tt.handlerErr != nil {
+					return tt.handlerErr
+				}
+				_, err := w.Write([]byte("partial"))
+				return err
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+			bw := &brokenPipeWriter{ResponseRecorder: httptest.NewRecorder()}
+			h.ServeHTTP(bw, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+			if bw.writes != tt.wantWrites {
+				t.Errorf("writes = %d, want %d", bw.writes, tt.wantWrites)
+			}
+			if bw.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", bw.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFileServerUntrusted(t *testing.T) {
+	files := http.FS(fstest.MapFS{
+		"evil.html":  {Data: []byte("<script>alert(1)</script>")},
+		"logo.svg":   {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)},
+		"photo.png":  {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"notes.txt":  {Data: []byte("hello")},
+		"noext":      {Data: []byte("<html><script>alert(1)</script>")},
+		"docs/a.pdf": {Data: []byte("%PDF-1.4")},
+	})
+	tests := []struct {
+		name        string
+		untrusted   bool
+		path        string
+		wantType    string
+		wantNosniff bool
+	}{
+		{"html neutralized", true, "/files/evil.html", "text/plain; charset=utf-8", true},
+		{"svg neutralized", true, "/files/logo.svg", "text/plain; charset=utf-8", true},
+		{"no extension neutralized", true, "/files/noext", "text/plain; charset=utf-8", true},
+		{"png kept", true, "/files/photo.png", "image/png", true},
+		{"pdf kept", true, "/files/docs/a.pdf", "application/pdf", true},
+		{"text kept", true, "/files/notes.txt", "text/plain; charset=utf-8", true},
+		{"trusted html", false, "/files/evil.html", "text/html; charset=utf-8", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			FileServerWithOptions(r, "/files", files, FileServerOptions{Untrusted: tt.untrusted})
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := rec.Header().Get("X-Content-Type-Options") == "nosniff"; got != tt.wantNosniff {
+				t.Errorf("nosniff = %v, want %v", got, tt.wantNosniff)
+			}
+		})
+	}
+}
+
+func TestHandleAll(t *testing.T) {
+	r := chi.NewRouter()
+	HandleAll(r, "/hooks/{source}", func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte(r.Method + " " + chi.URLParam(r, "source")))
+		return nil
+	})
+
+	tests := []struct {
+		method string
+	}{
+		{http.MethodGet},
+		{http.MethodPost},
+		{http.MethodPut},
+		{http.MethodDelete},
+		{http.MethodPatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, "/hooks/github", nil))
+			if want := tt.method + " github"; rec.Code != http.StatusOK || rec.Body.String() != want {
+				t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), want)
+			}
+		})
+	}
+}
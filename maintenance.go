@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-chi/render"
+)
+
+// MaintenancePage is served, with a 503, to every request while maintenance
+// mode is on, unless ErrorPages has a page for 503.
+var MaintenancePage = []byte(`<!doctype html>
+<title>Down for maintenance</title>
+<h1>We'll be right back</h1>
+<p>The site is down for scheduled maintenance. Please try again shortly.</p>
+`)
+
+// MaintenanceRetryAfter is the Retry-After value, in seconds, sent with the
+// maintenance page.
+var MaintenanceRetryAfter = 300
+
+// maintenanceTogglePath stays reachable in maintenance mode so that it can
+// be switched off again.
+const maintenanceTogglePath = "/admin/maintenance"
+
+var maintenance atomic.Bool
+
+// SetMaintenance switches maintenance mode on or off.
+func SetMaintenance(on bool) {
+	maintenance.Store(on)
+}
+
+// InMaintenance reports whether maintenance mode is on.
+func InMaintenance() bool {
+	return maintenance.Load()
+}
+
+// Maintenance serves MaintenancePage with a 503 for everything except the
+// health checks and the maintenance toggle while maintenance mode is on.
+func Maintenance(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !InMaintenance() || isHealthPath(r) || r.URL.Path == maintenanceTogglePath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(MaintenanceRetryAfter))
+		if serveErrorPage(w, r, http.StatusServiceUnavailable) {
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(MaintenancePage)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// maintenanceHandler sets maintenance mode from the "enabled" query
+// parameter, or flips it when the parameter is absent.
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	on := !InMaintenance()
+	if v := r.URL.Query().Get("enabled"); v != "" {
+		var err error
+		if on, err = strconv.ParseBool(v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "enabled must be true or false")
+			return
+		}
+	}
+	SetMaintenance(on)
+	render.JSON(w, r, map[string]bool{"maintenance": on})
+}
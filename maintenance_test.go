@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMaintenanceToggle(t *testing.T) {
+	SetMaintenance(false)
+	t.Cleanup(func() { SetMaintenance(false) })
+
+	r := chi.NewRouter()
+	r.Use(Maintenance)
+	r.Get("/", okHandler)
+	r.Get("/healthz", healthHandler)
+	r.Post(maintenanceTogglePath, maintenanceHandler)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{"off serves normally", http.MethodGet, "/", http.StatusOK},
+		{"switch on", http.MethodPost, maintenanceTogglePath + "?enabled=true", http.StatusOK},
+		{"on serves the maintenance page", http.MethodGet, "/", http.StatusServiceUnavailable},
+		{"on still serves health checks", http.MethodGet, "/healthz", http.StatusOK},
+		{"bad toggle value", http.MethodPost, maintenanceTogglePath + "?enabled=maybe", http.StatusBadRequest},
+		{"switch off by flipping", http.MethodPost, maintenanceTogglePath, http.StatusOK},
+		{"off again", http.MethodGet, "/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusServiceUnavailable {
+				if rec.Header().Get("Retry-After") != "300" || rec.Body.String() != string(MaintenancePage) {
+					t.Errorf("maintenance response = %v %q", rec.Header(), rec.Body)
+				}
+			}
+		})
+	}
+}
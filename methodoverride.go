@@ -0,0 +1,40 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// overridableMethods are the methods MethodOverride will switch a POST to.
+var overridableMethods = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// MethodOverride lets HTML forms, which can only GET or POST, reach PUT,
+// PATCH and DELETE routes: a POST with an X-HTTP-Method-Override header, or
+// a _method field in a URL-encoded form body, is routed as that method.
+// Other override values, and requests that aren't POSTs, are left alone. It
+// has to run before routing, so register it with Use on the root router.
+func MethodOverride(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			method := r.Header.Get("X-HTTP-Method-Override")
+			if method == "" && isURLEncodedForm(r) {
+				method = r.PostFormValue("_method")
+			}
+			if method = strings.ToUpper(strings.TrimSpace(method)); overridableMethods[method] {
+				r.Method = method
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func isURLEncodedForm(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
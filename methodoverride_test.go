@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMethodOverride(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(MethodOverride)
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		method := method
+		r.MethodFunc(method, "/items/1", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(method))
+		})
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		override    string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"header", http.MethodPost, "PUT", "", "", "PUT"},
+		{"header lower case", http.MethodPost, "delete", "", "", "DELETE"},
+		{"form field", http.MethodPost, "", "application/x-www-form-urlencoded", "_method=PATCH&name=x", "PATCH"},
+		{"header wins over form", http.MethodPost, "PUT", "application/x-www-form-urlencoded", "_method=DELETE", "PUT"},
+		{"field in JSON body ignored", http.MethodPost, "", "application/json", `{"_method":"PUT"}`, "POST"},
+		{"plain POST", http.MethodPost, "", "", "", "POST"},
+		{"unsafe override ignored", http.MethodPost, "CONNECT", "", "", "POST"},
+		{"downgrade to GET ignored", http.MethodPost, "GET", "", "", "POST"},
+		{"only POST overridden", http.MethodGet, "DELETE", "", "", "GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/items/1", strings.NewReader(tt.body))
+			if tt.override != "" {
+				req.Header.Set("X-HTTP-Method-Override", tt.override)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("routed as %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MethodAllowlist rejects any request whose method is not in methods with a
+// 405, whatever routes are registered. It suits read-only mirrors, e.g.
+// MethodAllowlist("GET", "HEAD").
+func MethodAllowlist(methods ...string) func(next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(methods))
+	names := make([]string, len(methods))
+	for i, m := range methods {
+		names[i] = strings.ToUpper(m)
+		allowed[names[i]] = true
+	}
+	allow := strings.Join(names, ", ")
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !allowed[r.Method] {
+				w.Header().Set("Allow", allow)
+				writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RejectBodyOnGet is an opt-in strict mode that rejects GET, HEAD and DELETE
+// requests carrying a body with a 400. Such bodies are almost always client
+// bugs, and intermediaries handle them inconsistently.
+func RejectBodyOnGet(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			if hasBody(r) {
+				writeError(w, r, http.StatusBadRequest, r.Method+" requests must not have a body")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// hasBody reports whether r has a non-empty body. When the length isn't
+// known up front it reads a byte to find out, so it must only be used where
+// a non-empty body is going to be rejected.
+func hasBody(r *http.Request) bool {
+	if r.ContentLength > 0 {
+		return true
+	}
+	if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	var b [1]byte
+	n, _ := io.ReadFull(r.Body, b[:])
+	return n > 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodAllowlist(t *testing.T) {
+	h := MethodAllowlist("get", "HEAD")(okHandler)
+
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodHead, http.StatusOK},
+		{http.MethodPost, http.StatusMethodNotAllowed},
+		{http.MethodDelete, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/mirror/x", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusMethodNotAllowed && rec.Header().Get("Allow") != "GET, HEAD" {
+				t.Errorf("Allow = %q, want %q", rec.Header().Get("Allow"), "GET, HEAD")
+			}
+		})
+	}
+}
+
+func TestRejectBodyOnGet(t *testing.T) {
+	h := RejectBodyOnGet(okHandler)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		chunked    bool
+		wantStatus int
+	}{
+		{"GET without body", http.MethodGet, "", false, http.StatusOK},
+		{"GET with body", http.MethodGet, "x=1", false, http.StatusBadRequest},
+		{"GET with chunked body", http.MethodGet, "x=1", true, http.StatusBadRequest},
+		{"GET with empty chunked body", http.MethodGet, "", true, http.StatusOK},
+		{"DELETE with body", http.MethodDelete, "{}", false, http.StatusBadRequest},
+		{"POST with body", http.MethodPost, "{}", false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" || tt.chunked {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, "/", body)
+			if tt.chunked {
+				// Unknown length, as with Transfer-Encoding: chunked.
+				req.ContentLength = -1
+				req.Body = io.NopCloser(strings.NewReader(tt.body))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
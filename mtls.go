@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+)
+
+// RequireClientCert guards mTLS routes: the request must have arrived over
+// TLS with a client certificate the server verified, which needs ClientAuth
+// set to at least tls.VerifyClientCertIfGiven on the http.Server. When
+// allowedCNs is not empty, the certificate's subject common name must also
+// be one of them. Anything else gets a 403.
+func RequireClientCert(allowedCNs []string) func(next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedCNs))
+	for _, cn := range allowedCNs {
+		allowed[cn] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				writeError(w, r, http.StatusForbidden, "client certificate required")
+				return
+			}
+			cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+			if len(allowed) > 0 && !allowed[cn] {
+				writeError(w, r, http.StatusForbidden, "client certificate not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
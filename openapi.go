@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// RouteMeta documents a route in the generated OpenAPI document.
+type RouteMeta struct {
+	Summary     string
+	Description string
+
+	// RequestSchema describes the JSON request body. It defaults to the
+	// schema registered for the route with RegisterSchema, if any.
+	RequestSchema *Schema
+
+	// ResponseSchema describes the JSON body of a successful response.
+	ResponseSchema *Schema
+}
+
+var (
+	routeMetaMu sync.RWMutex
+	routeMeta   = make(map[string]RouteMeta)
+)
+
+// DescribeRoute attaches meta to the route with the given method and
+// pattern in /openapi.json.
+func DescribeRoute(method, pattern string, meta RouteMeta) {
+	routeMetaMu.Lock()
+	defer routeMetaMu.Unlock()
+	routeMeta[method+" "+pattern] = meta
+}
+
+// routeParam matches a chi URL parameter, with or without a regexp.
+var routeParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIHandler serves a minimal OpenAPI 3 document listing every route on
+// root, with whatever DescribeRoute added. Wildcard routes, such as file
+// servers and proxies, are left out since OpenAPI can't express them.
+func openAPIHandler(root chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		paths := map[string]map[string]any{}
+		err := chi.Walk(root, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			if strings.Contains(route, "*") {
+				return nil
+			}
+			path := routeParam.ReplaceAllString(route, "{$1}")
+			if paths[path] == nil {
+				paths[path] = map[string]any{}
+			}
+			paths[path][strings.ToLower(method)] = openAPIOperation(method, route)
+			return nil
+		})
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		render.JSON(w, r, map[string]any{
+			"openapi": "3.0.3",
+			"info":    map[string]string{"title": "gochi", "version": "1.0.0"},
+			"paths":   paths,
+		})
+	}
+}
+
+func openAPIOperation(method, route string) map[string]any {
+	routeMetaMu.RLock()
+	meta := routeMeta[method+" "+route]
+	routeMetaMu.RUnlock()
+	if meta.RequestSchema == nil {
+		schemasMu.RLock()
+		meta.RequestSchema = schemas[method+" "+route]
+		schemasMu.RUnlock()
+	}
+
+	op := map[string]any{}
+	if meta.Summary != "" {
+		op["summary"] = meta.Summary
+	}
+	if meta.Description != "" {
+		op["description"] = meta.Description
+	}
+
+	var params []map[string]any
+	for _, m := range routeParam.FindAllStringSubmatch(route, -1) {
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": "string"},
+		})
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	if meta.RequestSchema != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(meta.RequestSchema),
+		}
+	}
+	ok := map[string]any{"description": "Success"}
+	if meta.ResponseSchema != nil {
+		ok["content"] = jsonContent(meta.ResponseSchema)
+	}
+	op["responses"] = map[string]any{"200": ok}
+	return op
+}
+
+func jsonContent(s *Schema) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": s}}
+}
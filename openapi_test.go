@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	t.Cleanup(func() {
+		routeMetaMu.Lock()
+		delete(routeMeta, "POST /users")
+		routeMetaMu.Unlock()
+	})
+	user := &Schema{Type: "object", Required: []string{"name"}, Properties: map[string]*Schema{"name": {Type: "string"}}}
+	DescribeRoute("POST", "/users", RouteMeta{Summary: "Create a user", RequestSchema: user, ResponseSchema: user})
+
+	r := chi.NewRouter()
+	r.Get("/users", okHandler.ServeHTTP)
+	r.Post("/users", okHandler.ServeHTTP)
+	r.Route("/users/{id:[0-9]+}", func(r chi.Router) {
+		r.Delete("/", okHandler.ServeHTTP)
+	})
+	r.Handle("/static/*", okHandler)
+	r.Get("/openapi.json", openAPIHandler(r))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+
+	userJSON := map[string]any{"application/json": map[string]any{"schema": map[string]any{
+		"type": "object", "required": []any{"name"}, "properties": map[string]any{"name": map[string]any{"type": "string"}},
+	}}}
+	tests := []struct {
+		path, method string
+		want         map[string]any
+	}{
+		{"/users", "get", map[string]any{
+			"responses": map[string]any{"200": map[string]any{"description": "Success"}},
+		}},
+		{"/users", "post", map[string]any{
+			"summary":     "Create a user",
+			"requestBody": map[string]any{"required": true, "content": userJSON},
+			"responses":   map[string]any{"200": map[string]any{"description": "Success", "content": userJSON}},
+		}},
+		{"/users/{id}/", "delete", map[string]any{
+			"parameters": []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}},
+			"responses":  map[string]any{"200": map[string]any{"description": "Success"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := doc.Paths[tt.path][tt.method]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("operation = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+	if _, ok := doc.Paths["/static/*"]; ok {
+		t.Error("wildcard route listed")
+	}
+	if len(doc.Paths) != 3 {
+		t.Errorf("paths = %v, want /users, /users/{id}/ and /openapi.json", keys(doc.Paths))
+	}
+}
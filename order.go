@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// middlewareDependencies lists middlewares that only work when another one
+// runs before them on the same route.
+var middlewareDependencies = []struct {
+	middleware string
+	requires   string
+}{
+	{"RateLimitByUser", "Authenticate"},
+	{"SequenceGuard", "Authenticate"},
+}
+
+// validateMiddlewareOrder walks every route on r and panics, naming the
+// route, if a middleware in middlewareDependencies is used without the one
+// it depends on earlier in the chain. Such mistakes don't fail at runtime,
+// they just quietly misbehave, so main checks once the routes are set up.
+func validateMiddlewareOrder(r chi.Router) {
+	err := chi.Walk(r, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		seen := make(map[string]bool, len(middlewares))
+		for _, mw := range middlewares {
+			name := middlewareName(mw)
+			for _, dep := range middlewareDependencies {
+				if dep.middleware == name && !seen[dep.requires] {
+					return fmt.Errorf("%s %s: %s must come after %s", method, route, dep.middleware, dep.requires)
+				}
+			}
+			seen[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		panic("middleware order: " + err.Error())
+	}
+}
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$`)
+
+// middlewareName identifies a middleware by the function that built it, so
+// that the closure returned by RateLimitByUser(...) is "RateLimitByUser".
+func middlewareName(mw func(http.Handler) http.Handler) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := closureSuffix.ReplaceAllString(fn.Name(), "")
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
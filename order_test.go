@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestValidateMiddlewareOrder(t *testing.T) {
+	auth := Authenticate("api", map[string]string{"ann": "secret"})
+	limit := RateLimitByUser(10, time.Minute)
+	tests := []struct {
+		name      string
+		setup     func(r chi.Router)
+		wantPanic string
+	}{
+		{"auth first", func(r chi.Router) {
+			r.Use(auth, limit)
+			r.Get("/orders", okHandler.ServeHTTP)
+		}, ""},
+		{"auth on parent router", func(r chi.Router) {
+			r.Use(auth)
+			r.Route("/api", func(r chi.Router) {
+				r.With(limit).Get("/orders", okHandler.ServeHTTP)
+			})
+		}, ""},
+		{"unrelated middleware", func(r chi.Router) {
+			r.Use(DefaultContentType("text/plain"))
+			r.Get("/orders", okHandler.ServeHTTP)
+		}, ""},
+		{"rate limit before auth", func(r chi.Router) {
+			r.Use(limit, auth)
+			r.Get("/orders", okHandler.ServeHTTP)
+		}, "middleware order: GET /orders: RateLimitByUser must come after Authenticate"},
+		{"rate limit without auth", func(r chi.Router) {
+			r.Get("/public", okHandler.ServeHTTP)
+			r.Route("/api", func(r chi.Router) {
+				r.With(limit).Post("/orders", okHandler.ServeHTTP)
+			})
+		}, "middleware order: POST /api/orders: RateLimitByUser must come after Authenticate"},
+		{"sequence guard before auth", func(r chi.Router) {
+			r.With(SequenceGuard(time.Minute), auth).Put("/doc", okHandler.ServeHTTP)
+		}, "middleware order: PUT /doc: SequenceGuard must come after Authenticate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			tt.setup(r)
+
+			var got string
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						got = fmt.Sprint(p)
+					}
+				}()
+				validateMiddlewareOrder(r)
+			}()
+			if got != tt.wantPanic {
+				t.Errorf("panic = %q, want %q", got, tt.wantPanic)
+			}
+		})
+	}
+}
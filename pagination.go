@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type pageKey struct{}
+
+// PageParams is a validated ?page=&per_page= pair. Page starts at 1.
+type PageParams struct {
+	Page    int
+	PerPage int
+}
+
+// Offset is the number of items before the first one on the page.
+func (p PageParams) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// Pagination parses the page and per_page query parameters of list
+// endpoints, defaulting to the first page of defaultPerPage items. Values
+// that aren't positive integers, or a per_page above maxPerPage, are
+// rejected with a 400. Handlers read the result with PageFromContext.
+func Pagination(defaultPerPage, maxPerPage int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			p := PageParams{Page: 1, PerPage: defaultPerPage}
+			q := r.URL.Query()
+			if v := q.Get("page"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					writeError(w, r, http.StatusBadRequest, "page must be a positive integer")
+					return
+				}
+				p.Page = n
+			}
+			if v := q.Get("per_page"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 {
+					writeError(w, r, http.StatusBadRequest, "per_page must be a positive integer")
+					return
+				}
+				if n > maxPerPage {
+					writeError(w, r, http.StatusBadRequest, fmt.Sprintf("per_page must be at most %d", maxPerPage))
+					return
+				}
+				p.PerPage = n
+			}
+			ctx := context.WithValue(r.Context(), pageKey{}, p)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// PageFromContext returns the pagination parameters Pagination parsed, and
+// false if it didn't run.
+func PageFromContext(ctx context.Context) (PageParams, bool) {
+	p, ok := ctx.Value(pageKey{}).(PageParams)
+	return p, ok
+}
+
+// PageURL returns r's URL, path and query, pointing at the given page of the
+// same listing.
+func PageURL(r *http.Request, p PageParams, page int) string {
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	q.Set("per_page", strconv.Itoa(p.PerPage))
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// SetPageLinks adds a Link header pointing at the previous page, unless p is
+// the first one, and at the next page if hasNext is true. Links set by
+// others, such as Deprecated's, are kept.
+func SetPageLinks(w http.ResponseWriter, r *http.Request, p PageParams, hasNext bool) {
+	var links []string
+	if p.Page > 1 {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", PageURL(r, p, p.Page-1)))
+	}
+	if hasNext {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", PageURL(r, p, p.Page+1)))
+	}
+	if len(links) > 0 {
+		w.Header().Add("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		want       PageParams
+	}{
+		{"defaults", "", http.StatusOK, PageParams{Page: 1, PerPage: 20}},
+		{"explicit", "?page=3&per_page=50", http.StatusOK, PageParams{Page: 3, PerPage: 50}},
+		{"zero page", "?page=0", http.StatusBadRequest, PageParams{}},
+		{"not a number", "?per_page=lots", http.StatusBadRequest, PageParams{}},
+		{"over max", "?per_page=101", http.StatusBadRequest, PageParams{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got PageParams
+			h := Pagination(20, 100)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = PageFromContext(r.Context())
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got != tt.want {
+				t.Errorf("params = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPageLinks(t *testing.T) {
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		query      string
+		hasNext    bool
+		deprecated bool
+		want       []string
+	}{
+		{"first page", "?page=1", true, false, []string{
+			`</users?page=2&per_page=10&sort=name>; rel="next"`,
+		}},
+		{"middle page", "?page=2", true, false, []string{
+			`</users?page=1&per_page=10&sort=name>; rel="prev", </users?page=3&per_page=10&sort=name>; rel="next"`,
+		}},
+		{"last page", "?page=3", false, false, []string{
+			`</users?page=2&per_page=10&sort=name>; rel="prev"`,
+		}},
+		{"only page", "?page=1", false, false, nil},
+		{"deprecation link kept", "?page=1", true, true, []string{
+			`</docs/v2>; rel="deprecation"`,
+			`</users?page=2&per_page=10&sort=name>; rel="next"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h http.Handler = Pagination(10, 100)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				p, _ := PageFromContext(r.Context())
+				SetPageLinks(w, r, p, tt.hasNext)
+			}))
+			if tt.deprecated {
+				h = Deprecated(sunset, "/docs/v2")(h)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users"+tt.query+"&sort=name", nil))
+
+			if got := rec.Header()["Link"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ReverseProxy forwards every request under mount to the upstream base URL
+// target, with the mount prefix stripped. Upstream requests carry our
+// X-Request-ID, responses are stripped of hop-by-hop headers, and upstream
+// failures are answered with a 502 in the standard error envelope.
+func ReverseProxy(r chi.Router, mount, target string) {
+	upstream, err := url.Parse(target)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		panic("ReverseProxy needs an absolute upstream URL, got " + target)
+	}
+	mount = strings.TrimSuffix(mount, "/")
+
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Header.Set(middleware.RequestIDHeader, RequestID(req))
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		if clientGone(req, err) {
+			return
+		}
+		log.Printf("[%s] proxy to %s: %v", RequestID(req), target, err)
+		writeError(w, req, http.StatusBadGateway, "upstream unavailable")
+	}
+
+	r.With(StripHopByHop).Handle(mount+"/*", http.StripPrefix(mount, proxy))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestReverseProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.Path)
+		w.Header().Set("X-Upstream-Request-Id", r.Header.Get(middleware.RequestIDHeader))
+		w.Write([]byte("from upstream"))
+	}))
+	defer upstream.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	tests := []struct {
+		name       string
+		target     string
+		requestID  bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"success", upstream.URL, true, http.StatusOK, "from upstream"},
+		{"upstream down", downURL, true, http.StatusBadGateway, ""},
+		{"success without RequestID", upstream.URL, false, http.StatusOK, "from upstream"},
+		{"upstream down without RequestID", downURL, false, http.StatusBadGateway, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			r := chi.NewRouter()
+			if tt.requestID {
+				r.Use(middleware.RequestID)
+			}
+			ReverseProxy(r, "/proxy", tt.target)
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/proxy/widgets/1", nil)
+			if tt.requestID {
+				req.Header.Set(middleware.RequestIDHeader, "req-42")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var env errorResponse
+				if err := json.Unmarshal(body, &env); err != nil || env.Status != tt.wantStatus || env.RequestID == "" {
+					t.Fatalf("body = %s, want the error envelope", body)
+				}
+				if tt.requestID && env.RequestID != "req-42" {
+					t.Errorf("request ID = %q, want req-42", env.RequestID)
+				}
+				if !strings.Contains(logs.String(), "["+env.RequestID+"] proxy to") {
+					t.Errorf("log = %q, want it tagged with %s", logs.String(), env.RequestID)
+				}
+				return
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got := resp.Header.Get("X-Upstream-Path"); got != "/widgets/1" {
+				t.Errorf("upstream path = %q, want /widgets/1", got)
+			}
+			got := resp.Header.Get("X-Upstream-Request-Id")
+			if tt.requestID && got != "req-42" {
+				t.Errorf("upstream request ID = %q, want req-42", got)
+			}
+			if got == "" {
+				t.Error("upstream got no request ID")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyFunc picks the identity a request is rate limited as.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP rate limits each client IP separately.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// KeyByUser rate limits each authenticated user separately, so that users
+// sharing a NAT don't share a budget, and falls back to the client IP for
+// anonymous requests. It must run after Authenticate; use RateLimitByUser
+// to have that checked at startup.
+func KeyByUser(r *http.Request) string {
+	if user, ok := UserFromContext(r.Context()); ok {
+		return "user:" + user
+	}
+	return KeyByIP(r)
+}
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// RateLimit allows each key, as chosen by key, up to limit requests per
+// window and answers the rest with a 429 until the window resets.
+func RateLimit(limit int, window time.Duration, key KeyFunc) func(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+	lastSweep := time.Now()
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			now := time.Now()
+
+			mu.Lock()
+			if now.Sub(lastSweep) >= window {
+				for id, rw := range windows {
+					if now.After(rw.reset) {
+						delete(windows, id)
+					}
+				}
+				lastSweep = now
+			}
+			rw, ok := windows[k]
+			if !ok || now.After(rw.reset) {
+				rw = &rateWindow{reset: now.Add(window)}
+				windows[k] = rw
+			}
+			rw.count++
+			count, reset := rw.count, rw.reset
+			mu.Unlock()
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(0, limit-count)))
+			if count > limit {
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(time.Until(reset).Seconds()+0.5))))
+				writeLimitError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RateLimitByUser is RateLimit keyed by KeyByUser. Unlike passing KeyByUser
+// to RateLimit directly, validateMiddlewareOrder can check that it runs
+// after Authenticate.
+func RateLimitByUser(limit int, window time.Duration) func(next http.Handler) http.Handler {
+	limiter := RateLimit(limit, window, KeyByUser)
+	return func(next http.Handler) http.Handler {
+		return limiter(next)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRateLimitByUser(t *testing.T) {
+	creds := map[string]string{"alice": "pw-a", "bob": "pw-b"}
+	h := Authenticate("test", creds)(RateLimitByUser(2, time.Minute)(okHandler))
+
+	// Every request comes from the same IP, as if behind one NAT.
+	tests := []struct {
+		name          string
+		user          string
+		wantStatus    int
+		wantRemaining string
+	}{
+		{"alice 1", "alice", http.StatusOK, "1"},
+		{"alice 2", "alice", http.StatusOK, "0"},
+		{"alice over", "alice", http.StatusTooManyRequests, "0"},
+		{"bob unaffected", "bob", http.StatusOK, "1"},
+		{"anonymous by IP", "", http.StatusOK, "1"},
+		{"bob 2", "bob", http.StatusOK, "0"},
+		{"bob over", "bob", http.StatusTooManyRequests, "0"},
+		{"anonymous 2", "", http.StatusOK, "0"},
+		{"anonymous over", "", http.StatusTooManyRequests, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:1234"
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, creds[tt.user])
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("X-RateLimit-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+				t.Error("missing Retry-After")
+			}
+		})
+	}
+}
+
+func TestRateLimitByUserNeedsAuthenticate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RateLimitByUser without Authenticate did not panic")
+		}
+	}()
+	r := chi.NewRouter()
+	r.With(RateLimitByUser(1, time.Minute)).Get("/", okHandler)
+	validateMiddlewareOrder(r)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Recover turns a panic anywhere below it, including in mounted sub-routers
+// and plain http.Handlers registered with Handle or Mount, into our JSON
+// error envelope with a 500. The panic is logged with its stack and the
+// route pattern that was being served. Like middleware.Recoverer, it lets
+// http.ErrAbortHandler through so the server can abort the response.
+func Recover(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			slog.Error("panic serving request",
+				"request_id", RequestID(r),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", routePattern(r),
+				"panic", p,
+				"stack", string(debug.Stack()))
+			recordError(r, http.StatusInternalServerError, fmt.Sprintf("panic: %v", p))
+
+			// Nothing useful can be sent once the response has started, or
+			// on a connection that was being upgraded.
+			if ww.Status() != 0 || r.Header.Get("Connection") == "Upgrade" {
+				return
+			}
+			writeError(ww, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}()
+		next.ServeHTTP(ww, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRecover(t *testing.T) {
+	sub := chi.NewRouter()
+	sub.Get("/reports/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("report generator exploded")
+	})
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/legacy/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("legacy handler exploded")
+	})
+
+	r := chi.NewRouter()
+	r.Use(Recover)
+	r.Mount("/admin", sub)
+	r.Handle("/legacy/*", legacy)
+	r.Get("/partial", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("half a resp"))
+		panic("mid-response")
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		wantEnvelope bool
+		wantRoute    string
+	}{
+		{"mounted chi router", "/admin/reports/7", true, "/admin/reports/{id}"},
+		{"mounted stdlib handler", "/legacy/boom", true, "/legacy/*"},
+		{"response already started", "/partial", false, "/partial"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if !strings.Contains(logs.String(), "route="+tt.wantRoute) {
+				t.Errorf("route %q not logged; log: %q", tt.wantRoute, logs.String())
+			}
+			if !tt.wantEnvelope {
+				if rec.Body.String() != "half a resp" {
+					t.Errorf("body = %q, want the partial response untouched", rec.Body.String())
+				}
+				return
+			}
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want 500", rec.Code)
+			}
+			var env errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil || env.Status != http.StatusInternalServerError || env.RequestID == "" {
+				t.Errorf("body = %s, want the error envelope", rec.Body)
+			}
+		})
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler to propagate", p)
+		}
+	}()
+	Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RedirectAfterPost answers a form POST with a 303 See Other to target, for
+// the POST-redirect-GET pattern. target must be a relative path or a URL on
+// the request's own host; anything else is refused with a 400 HTTPError so
+// that user-supplied targets can't be used as open redirects.
+func RedirectAfterPost(w http.ResponseWriter, r *http.Request, target string) error {
+	if !sameOrigin(r, target) {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "redirect target must be on this site"}
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+	return nil
+}
+
+// sameOrigin reports whether target stays on the site serving r.
+func sameOrigin(r *http.Request, target string) bool {
+	// Browsers treat backslashes like slashes, so "/\evil.com" is as
+	// dangerous as "//evil.com".
+	if strings.ContainsAny(target, "\\\r\n") {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return u.Opaque == "" && !strings.HasPrefix(target, "//")
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, r.Host)
+}
+
+// redirectCountCookie carries the number of redirects in a row so far, for
+// RedirectLoopGuard. A cookie rather than the Location's query string
+// leaves redirect targets, which browsers and caches may keep, untouched.
+const redirectCountCookie = "_redirects"
+
+// redirectCountMaxAge bounds how long a redirect count is kept, in seconds,
+// should a chain of redirects be abandoned halfway.
+const redirectCountMaxAge = 60
+
+// RedirectLoopGuard breaks redirect loops caused by conflicting rules, e.g.
+// a trailing-slash redirect and a rewrite that undo each other. Every
+// same-site redirect sets a cookie counting the redirects in a row, any
+// other response clears it, and a request arriving after maxRedirects
+// redirects in a row is answered with a 508 Loop Detected, and logged,
+// instead of being redirected again. Clients that don't keep cookies are
+// left to their own redirect limits.
+func RedirectLoopGuard(maxRedirects int) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var count int
+			if c, err := r.Cookie(redirectCountCookie); err == nil {
+				count, _ = strconv.Atoi(c.Value)
+			}
+			if count >= maxRedirects {
+				log.Printf("[%s] redirect loop detected at %s after %d redirects", RequestID(r), r.URL.Path, count)
+				setRedirectCount(w, 0)
+				writeError(w, r, http.StatusLoopDetected, "redirect loop detected")
+				return
+			}
+			rw := &redirectCountWriter{ResponseWriter: w, r: r, count: count}
+			next.ServeHTTP(rw, r)
+			if !rw.wroteHeader {
+				// An empty response is a 200, and ends the chain.
+				rw.track(http.StatusOK)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// setRedirectCount sets the redirect count cookie to count, or clears it
+// when count is zero.
+func setRedirectCount(w http.ResponseWriter, count int) {
+	c := &http.Cookie{
+		Name:     redirectCountCookie,
+		Value:    strconv.Itoa(count),
+		Path:     "/",
+		MaxAge:   redirectCountMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if count == 0 {
+		c.Value, c.MaxAge = "", -1
+	}
+	http.SetCookie(w, c)
+}
+
+// redirectCountWriter counts same-site redirects, and resets the count on
+// any other response.
+type redirectCountWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	count       int
+	wroteHeader bool
+}
+
+func (rw *redirectCountWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.wroteHeader = true
+		rw.track(code)
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// track updates the redirect count cookie for a response with status code.
+func (rw *redirectCountWriter) track(code int) {
+	loc := rw.Header().Get("Location")
+	if code >= 300 && code < 400 && loc != "" && sameOrigin(rw.r, loc) {
+		setRedirectCount(rw, rw.count+1)
+	} else if rw.count > 0 {
+		setRedirectCount(rw, 0)
+	}
+}
+
+func (rw *redirectCountWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+func (rw *redirectCountWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *redirectCountWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
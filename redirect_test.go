@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRedirectAfterPost(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"relative path", "/orders/42", http.StatusSeeOther, "/orders/42"},
+		{"same host", "https://example.com/orders", http.StatusSeeOther, "https://example.com/orders"},
+		{"other host", "https://evil.com/", http.StatusBadRequest, ""},
+		{"protocol-relative", "//evil.com/", http.StatusBadRequest, ""},
+		{"backslash", "/\\evil.com", http.StatusBadRequest, ""},
+		{"javascript", "javascript:alert(1)", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				return RedirectAfterPost(w, r, tt.target)
+			})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "https://example.com/orders", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestRedirectLoopGuard(t *testing.T) {
+	captureLog(t)
+	r := chi.NewRouter()
+	r.Use(RedirectLoopGuard(5))
+	// /a and /b redirect to each other forever.
+	r.Get("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b?z=1&a=2", http.StatusMovedPermanently)
+	})
+	r.Get("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/a", http.StatusFound)
+	})
+	// /start redirects once, to a page.
+	r.Get("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	r.Get("/page", okHandler.ServeHTTP)
+	// /start-empty redirects once, to a handler that writes nothing.
+	r.Get("/start-empty", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/empty", http.StatusFound)
+	})
+	r.Get("/empty", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name          string
+		paths         []string
+		wantStatus    int
+		wantRedirects int
+	}{
+		{"loop broken", []string{"/a"}, http.StatusLoopDetected, 5},
+		{"single redirect", []string{"/start"}, http.StatusOK, 1},
+		{"count reset between chains", []string{"/start", "/start", "/start", "/start", "/start", "/start"}, http.StatusOK, 1},
+		{"count reset by an empty response", []string{"/start-empty", "/start-empty", "/start-empty", "/start-empty", "/start-empty"}, http.StatusOK, 1},
+		{"retry after loop", []string{"/a", "/start"}, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jar, _ := cookiejar.New(nil)
+			var redirects int
+			client := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				redirects = len(via)
+				if len(via) > 50 {
+					return errors.New("client gave up first")
+				}
+				return nil
+			}}
+			var resp *http.Response
+			for _, path := range tt.paths {
+				redirects = 0
+				var err error
+				if resp, err = client.Get(srv.URL + path); err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if redirects != tt.wantRedirects {
+				t.Errorf("followed %d redirects, want %d", redirects, tt.wantRedirects)
+			}
+		})
+	}
+
+	t.Run("Location untouched", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+		if got := rec.Header().Get("Location"); got != "/b?z=1&a=2" {
+			t.Errorf("Location = %q, want it as the handler set it", got)
+		}
+	})
+}
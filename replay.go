@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NonceStore remembers the nonces ReplayGuard has seen. Implementations must
+// be safe for concurrent use; a shared backend lets several instances
+// reject each other's replays.
+type NonceStore interface {
+	// Remember records nonce for ttl and reports whether it was already
+	// recorded and not yet expired.
+	Remember(nonce string, ttl time.Duration) (seen bool, err error)
+}
+
+// MemoryNonceStore is an in-process NonceStore. Expired nonces are swept out
+// as new ones arrive.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{expires: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+func (s *MemoryNonceStore) Remember(nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= ttl {
+		for n, exp := range s.expires {
+			if now.After(exp) {
+				delete(s.expires, n)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if exp, ok := s.expires[nonce]; ok && now.Before(exp) {
+		return true, nil
+	}
+	s.expires[nonce] = now.Add(ttl)
+	return false, nil
+}
+
+// ReplayGuard rejects replayed requests. Each request must carry a unique
+// X-Request-Nonce header: a missing nonce gets a 400, and one already seen
+// within ttl gets a 409 Conflict.
+func ReplayGuard(store NonceStore, ttl time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			nonce := r.Header.Get("X-Request-Nonce")
+			if nonce == "" {
+				writeError(w, r, http.StatusBadRequest, "missing X-Request-Nonce header")
+				return
+			}
+			seen, err := store.Remember(nonce, ttl)
+			if err != nil {
+				log.Printf("[%s] nonce store: %v", RequestID(r), err)
+				writeError(w, r, http.StatusInternalServerError, "could not check request nonce")
+				return
+			}
+			if seen {
+				writeError(w, r, http.StatusConflict, "request has already been processed")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
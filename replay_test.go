@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	h := ReplayGuard(NewMemoryNonceStore(), ttl)(okHandler)
+
+	tests := []struct {
+		name       string
+		nonce      string
+		wait       time.Duration
+		wantStatus int
+	}{
+		{"missing nonce", "", 0, http.StatusBadRequest},
+		{"first use", "abc", 0, http.StatusOK},
+		{"replay", "abc", 0, http.StatusConflict},
+		{"other nonce", "def", 0, http.StatusOK},
+		{"after ttl", "abc", 2 * ttl, http.StatusOK},
+		{"replay after reuse", "abc", 0, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Sleep(tt.wait)
+			req := httptest.NewRequest(http.MethodPost, "/transfer", nil)
+			if tt.nonce != "" {
+				req.Header.Set("X-Request-Nonce", tt.nonce)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMemoryNonceStoreSweeps(t *testing.T) {
+	s := NewMemoryNonceStore()
+	s.Remember("old", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	s.Remember("new", time.Millisecond)
+	if _, ok := s.expires["old"]; ok {
+		t.Error("expired nonce was not swept")
+	}
+}
+
+type failingNonceStore struct{}
+
+func (failingNonceStore) Remember(string, time.Duration) (bool, error) {
+	return false, errors.New("backend down")
+}
+
+func TestReplayGuardStoreError(t *testing.T) {
+	captureLog(t)
+	req := httptest.NewRequest(http.MethodPost, "/transfer", nil)
+	req.Header.Set("X-Request-Nonce", "abc")
+	rec := httptest.NewRecorder()
+	ReplayGuard(failingNonceStore{}, time.Minute)(okHandler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestID returns the ID middleware.RequestID gave r. Without that
+// middleware it generates a short random ID instead and stores it in r's
+// context, where later calls for the same request, and GetReqID, find it.
+// Features can therefore rely on a correlation ID whatever the middleware
+// order.
+func RequestID(r *http.Request) string {
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		return id
+	}
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	id := hex.EncodeToString(b)
+	*r = *r.WithContext(context.WithValue(r.Context(), middleware.RequestIDKey, id))
+	return id
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRequestID(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{12}$`)
+	tests := []struct {
+		name       string
+		middleware bool
+		header     string
+		want       string
+	}{
+		{"from middleware header", true, "client-id-1", "client-id-1"},
+		{"generated by middleware", true, "", ""},
+		{"absent middleware", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var first, second, fromCtx string
+			var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				first = RequestID(r)
+				second = RequestID(r)
+				fromCtx = middleware.GetReqID(r.Context())
+			})
+			if tt.middleware {
+				h = middleware.RequestID(h)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(middleware.RequestIDHeader, tt.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			switch {
+			case tt.want != "" && first != tt.want:
+				t.Errorf("RequestID = %q, want %q", first, tt.want)
+			case first == "":
+				t.Error("RequestID is empty")
+			case !tt.middleware && !generated.MatchString(first):
+				t.Errorf("generated ID %q, want 12 hex digits", first)
+			}
+			if second != first || fromCtx != first {
+				t.Errorf("IDs for one request differ: %q, %q, GetReqID %q", first, second, fromCtx)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// contentTypeCSV extends render's content types with CSV, which render
+// itself doesn't know how to write.
+const contentTypeCSV render.ContentType = 100
+
+// CSVMarshaler is implemented by values that Respond can write as CSV.
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
+
+var responseFormats = map[string]render.ContentType{
+	"json": render.ContentTypeJSON,
+	"xml":  render.ContentTypeXML,
+	"csv":  contentTypeCSV,
+}
+
+// FormatOverride lets a ?format=json|xml|csv query parameter take precedence
+// over the Accept header, for clients that can't set headers. Unknown
+// formats get a 400.
+func FormatOverride(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ct, ok := responseFormats[strings.ToLower(format)]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "unknown format "+format+", want json, xml or csv")
+			return
+		}
+		ctx := context.WithValue(r.Context(), render.ContentTypeCtxKey, ct)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Respond writes v in the format negotiated for r: the ?format override
+// when FormatOverride is in use, otherwise the Accept header. It adds CSV,
+// for values implementing CSVMarshaler or [][]string, to render.Respond's
+// JSON and XML.
+func Respond(w http.ResponseWriter, r *http.Request, v any) {
+	if !wantsCSV(r) {
+		render.Respond(w, r, v)
+		return
+	}
+
+	var records [][]string
+	switch v := v.(type) {
+	case CSVMarshaler:
+		var err error
+		if records, err = v.MarshalCSV(); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "could not encode CSV")
+			return
+		}
+	case [][]string:
+		records = v
+	default:
+		writeError(w, r, http.StatusNotAcceptable, "this resource is not available as CSV")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+	cw := csv.NewWriter(w)
+	cw.WriteAll(records)
+}
+
+func wantsCSV(r *http.Request) bool {
+	if ct, ok := r.Context().Value(render.ContentTypeCtxKey).(render.ContentType); ok {
+		return ct == contentTypeCSV
+	}
+	accept, _, _ := strings.Cut(r.Header.Get("Accept"), ",")
+	return strings.HasPrefix(strings.TrimSpace(accept), "text/csv")
+}
+
+// csvFlushRows is how many rows WriteCSV writes between flushes.
+const csvFlushRows = 100
+
+// WriteCSV streams an export as a CSV attachment: header, if not nil, then
+// every row received from rows until it is closed, flushing to the client
+// every 100 rows. It stops early, returning the context's error, if r is
+// cancelled, so producers should also watch r.Context() rather than block
+// sending. It returns the first write or encoding error, for the Handler to
+// deal with.
+func WriteCSV(w http.ResponseWriter, r *http.Request, header []string, rows <-chan []string) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment")
+	rc := http.NewResponseController(w)
+	cw := csv.NewWriter(w)
+
+	if header != nil {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	for n := 1; ; n++ {
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case row, ok := <-rows:
+			if !ok {
+				cw.Flush()
+				return cw.Error()
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		if n%csvFlushRows == 0 {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			rc.Flush()
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type respondPoint struct {
+	X int `json:"x" xml:"x"`
+	Y int `json:"y" xml:"y"`
+}
+
+func (p respondPoint) MarshalCSV() ([][]string, error) {
+	return [][]string{{"x", "y"}, {strconv.Itoa(p.X), strconv.Itoa(p.Y)}}, nil
+}
+
+func TestFormatOverride(t *testing.T) {
+	h := FormatOverride(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Respond(w, r, respondPoint{X: 1, Y: 2})
+	}))
+
+	tests := []struct {
+		name       string
+		query      string
+		accept     string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"json", "?format=json", "text/csv", http.StatusOK, "application/json", `{"x":1,"y":2}`},
+		{"xml", "?format=XML", "", http.StatusOK, "application/xml", `<respondPoint><x>1</x><y>2</y></respondPoint>`},
+		{"csv", "?format=csv", "application/json", http.StatusOK, "text/csv", "x,y\n1,2\n"},
+		{"accept header without override", "", "text/csv", http.StatusOK, "text/csv", "x,y\n1,2\n"},
+		{"invalid", "?format=yaml", "", http.StatusBadRequest, "application/json", `"unknown format yaml, want json, xml or csv"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/point"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      []string
+		rows        [][]string
+		wantFlushed bool
+	}{
+		{"quoting", []string{"id", "note"}, [][]string{
+			{"1", "plain"},
+			{"2", "has, comma"},
+			{"3", `has "quotes"`},
+			{"4", "two\nlines"},
+		}, false},
+		{"no header", nil, [][]string{{"1", "a"}}, false},
+		{"no rows", []string{"id"}, nil, false},
+		{"flushes as it goes", []string{"n"}, manyRows(csvFlushRows + 1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make(chan []string)
+			go func() {
+				defer close(rows)
+				for _, row := range tt.rows {
+					rows <- row
+				}
+			}()
+			rec := httptest.NewRecorder()
+			if err := WriteCSV(rec, httptest.NewRequest(http.MethodGet, "/export", nil), tt.header, rows); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != "attachment" {
+				t.Errorf("Content-Disposition = %q", got)
+			}
+			if rec.Flushed != tt.wantFlushed {
+				t.Errorf("flushed = %v, want %v", rec.Flushed, tt.wantFlushed)
+			}
+			got, err := csv.NewReader(rec.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("malformed CSV %q: %v", rec.Body, err)
+			}
+			want := tt.rows
+			if tt.header != nil {
+				want = append([][]string{tt.header}, tt.rows...)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("records = %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		rows := make(chan []string)
+		go func() {
+			rows <- []string{"1"}
+			cancel()
+		}()
+		done := make(chan error, 1)
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "/export", nil).WithContext(ctx)
+			done <- WriteCSV(httptest.NewRecorder(), req, []string{"n"}, rows)
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("WriteCSV kept waiting for rows after the request was cancelled")
+		}
+	})
+}
+
+func manyRows(n int) [][]string {
+	rows := make([][]string, n)
+	for i := range rows {
+		rows[i] = []string{strconv.Itoa(i)}
+	}
+	return rows
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type prefixRule struct {
+	from, to string
+}
+
+// Rewrite 301-redirects legacy paths to their new location, keeping the
+// query string. Rules map an old path to a new one, either exactly
+// ("/about-us" -> "/about") or by prefix when both end in "/*"
+// ("/old/*" -> "/new/*" sends /old/a/b to /new/a/b). Exact rules win over
+// prefix rules, and longer prefixes over shorter ones.
+func Rewrite(rules map[string]string) func(next http.Handler) http.Handler {
+	exact := make(map[string]string)
+	var prefixes []prefixRule
+	for from, to := range rules {
+		if strings.HasSuffix(from, "/*") && strings.HasSuffix(to, "/*") {
+			prefixes = append(prefixes, prefixRule{
+				from: strings.TrimSuffix(from, "*"),
+				to:   strings.TrimSuffix(to, "*"),
+			})
+			continue
+		}
+		if strings.ContainsRune(from, '*') || strings.ContainsRune(to, '*') {
+			panic("Rewrite: wildcard rules must end in /* on both sides: " + from + " -> " + to)
+		}
+		exact[from] = to
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i].from) > len(prefixes[j].from)
+	})
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			target, ok := exact[r.URL.Path]
+			if !ok {
+				for _, p := range prefixes {
+					if rest, found := strings.CutPrefix(r.URL.Path, p.from); found {
+						target, ok = p.to+rest, true
+						break
+					}
+				}
+			}
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	h := Rewrite(map[string]string{
+		"/about-us":  "/about",
+		"/old/*":     "/new/*",
+		"/old/api/*": "/api/v2/*",
+		"/old/exact": "/exact",
+	})(okHandler)
+
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"exact", "/about-us", http.StatusMovedPermanently, "/about"},
+		{"exact keeps query", "/about-us?ref=mail&b=2&a=1", http.StatusMovedPermanently, "/about?ref=mail&b=2&a=1"},
+		{"prefix", "/old/a/b", http.StatusMovedPermanently, "/new/a/b"},
+		{"prefix keeps query", "/old/a?x=1", http.StatusMovedPermanently, "/new/a?x=1"},
+		{"longest prefix wins", "/old/api/users", http.StatusMovedPermanently, "/api/v2/users"},
+		{"exact beats prefix", "/old/exact", http.StatusMovedPermanently, "/exact"},
+		{"no match", "/about", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
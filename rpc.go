@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+)
+
+// RPCMethod implements one JSON-RPC method. Returning an *RPCError sends it
+// to the client as is; any other error is reported as an internal error.
+type RPCMethod func(ctx context.Context, params json.RawMessage) (any, error)
+
+// RPCError is a JSON-RPC error object.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// RPCServer dispatches JSON-RPC 2.0 calls, single or batched, to registered
+// methods.
+type RPCServer struct {
+	methods map[string]RPCMethod
+}
+
+// maxRPCBodyBytes caps the size of a JSON-RPC request, batches included.
+const maxRPCBodyBytes = 1 << 20
+
+// NewRPCServer returns an RPCServer with no methods.
+func NewRPCServer() *RPCServer {
+	return &RPCServer{methods: make(map[string]RPCMethod)}
+}
+
+// Register makes m callable as name. It is not safe to call once the server
+// is handling requests.
+func (s *RPCServer) Register(name string, m RPCMethod) {
+	s.methods[name] = m
+}
+
+// ServeRPC is a Handler for the JSON-RPC endpoint. Protocol errors are
+// reported inside the JSON-RPC response; only transport failures, such as a
+// body over maxRPCBodyBytes, are returned as errors.
+func (s *RPCServer) ServeRPC(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBodyBytes))
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: "request body too large", Err: err}
+	}
+	if err != nil {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "could not read request body", Err: err}
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			render.JSON(w, r, rpcErrorResponse(nil, RPCParseError, "parse error"))
+			return nil
+		}
+		if len(batch) == 0 {
+			render.JSON(w, r, rpcErrorResponse(nil, RPCInvalidRequest, "invalid request"))
+			return nil
+		}
+		responses := make([]*rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			if resp := s.call(r, raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		render.JSON(w, r, responses)
+		return nil
+	}
+
+	if !json.Valid(body) {
+		render.JSON(w, r, rpcErrorResponse(nil, RPCParseError, "parse error"))
+		return nil
+	}
+	resp := s.call(r, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	render.JSON(w, r, resp)
+	return nil
+}
+
+// call runs a single request. It returns nil for notifications, which get
+// no response.
+func (s *RPCServer) call(r *http.Request, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcErrorResponse(req.ID, RPCInvalidRequest, "invalid request")
+	}
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		if req.ID == nil {
+			return nil
+		}
+		return rpcErrorResponse(req.ID, RPCMethodNotFound, "method not found")
+	}
+
+	result, err := m(r.Context(), req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rerr *RPCError
+		if errors.As(err, &rerr) {
+			return &rpcResponse{JSONRPC: "2.0", Error: rerr, ID: req.ID}
+		}
+		log.Printf("[%s] rpc %s: %v", RequestID(r), req.Method, err)
+		return rpcErrorResponse(req.ID, RPCInternalError, "internal error")
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("[%s] rpc %s: %v", RequestID(r), req.Method, err)
+		return rpcErrorResponse(req.ID, RPCInternalError, "internal error")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: b, ID: req.ID}
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, msg string) *rpcResponse {
+	return &rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: code, Message: msg}, ID: id}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRPCServer(t *testing.T) {
+	logs := captureLog(t)
+	rpc := NewRPCServer()
+	rpc.Register("add", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var args [2]int
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, &RPCError{Code: RPCInvalidParams, Message: "invalid params"}
+		}
+		return args[0] + args[1], nil
+	})
+	rpc.Register("fail", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return nil, errors.New("database is down")
+	})
+	h := Handler(rpc.ServeRPC)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			"single call",
+			`{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","result":3,"id":1}`,
+		},
+		{
+			"method not found",
+			`{"jsonrpc":"2.0","method":"sub","params":[1,2],"id":"a"}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":"a"}`,
+		},
+		{
+			"batch",
+			`[{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1},
+			  {"jsonrpc":"2.0","method":"add","params":[3,4]},
+			  {"jsonrpc":"2.0","method":"add","params":"x","id":2},
+			  {"jsonrpc":"2.0","method":"nope","id":3}]`,
+			http.StatusOK,
+			`[{"jsonrpc":"2.0","result":3,"id":1},
+			  {"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid params"},"id":2},
+			  {"jsonrpc":"2.0","error":{"code":-32601,"message":"method not found"},"id":3}]`,
+		},
+		{
+			"internal error",
+			`{"jsonrpc":"2.0","method":"fail","id":1}`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32603,"message":"internal error"},"id":1}`,
+		},
+		{
+			"notification only",
+			`{"jsonrpc":"2.0","method":"add","params":[1,2]}`,
+			http.StatusNoContent,
+			``,
+		},
+		{
+			"parse error",
+			`{"jsonrpc":`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`,
+		},
+		{
+			"empty batch",
+			`[]`,
+			http.StatusOK,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.want == "" {
+				if rec.Body.Len() != 0 {
+					t.Errorf("body = %s, want none", rec.Body)
+				}
+				return
+			}
+			var got, want any
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body is not JSON: %v: %s", err, rec.Body)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if gotJSON, wantJSON := mustJSON(t, got), mustJSON(t, want); gotJSON != wantJSON {
+				t.Errorf("response = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+	// Failures are logged with a request ID even without middleware.RequestID.
+	if !strings.Contains(logs.String(), "rpc fail: database is down") || strings.Contains(logs.String(), "[]") {
+		t.Errorf("log = %q, want the failure tagged with a request ID", logs.String())
+	}
+}
+
+// mustJSON marshals v, which sorts object keys, for comparing JSON values.
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRPCServerBodyLimit(t *testing.T) {
+	h := Handler(NewRPCServer().ServeRPC)
+	tests := []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{"at the limit", maxRPCBodyBytes, http.StatusOK},
+		{"over the limit", maxRPCBodyBytes + 1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A call padded with whitespace to the size wanted.
+			call := `{"jsonrpc":"2.0","method":"ping","id":1}`
+			body := call + strings.Repeat(" ", tt.size-len(call))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK && !strings.Contains(rec.Body.String(), `"error":"request body too large"`) {
+				t.Errorf("body = %s, want the error envelope", rec.Body)
+			}
+		})
+	}
+}
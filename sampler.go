@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"net/http"
+)
+
+type tracedKey struct{}
+
+// Sampler marks a fraction rate (0 to 1) of requests as traced, for
+// IsTraced to report, so that logging can be verbose for a sample of
+// traffic without the volume of logging everything. Requests are picked by
+// a hash of their request ID, so the decision is the same wherever it is
+// made for a given request. It must run after middleware.RequestID.
+//
+// The hash is SHA-256 rather than something cheaper like FNV, whose top
+// bits barely change between sequential IDs such as middleware.RequestID's,
+// which skews the sample.
+func Sampler(rate float64) func(next http.Handler) http.Handler {
+	threshold := uint64(rate * (math.MaxUint32 + 1))
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			sum := sha256.Sum256([]byte(RequestID(r)))
+			if uint64(binary.BigEndian.Uint32(sum[:4])) < threshold {
+				r = r.WithContext(context.WithValue(r.Context(), tracedKey{}, true))
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// IsTraced reports whether Sampler picked the request ctx belongs to for
+// verbose tracing.
+func IsTraced(ctx context.Context) bool {
+	traced, _ := ctx.Value(tracedKey{}).(bool)
+	return traced
+}
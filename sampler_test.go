@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestSampler(t *testing.T) {
+	const requests = 20000
+	tests := []struct {
+		name string
+		rate float64
+	}{
+		{"none", 0},
+		{"one percent", 0.01},
+		{"ten percent", 0.1},
+		{"half", 0.5},
+		{"all", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traced := 0
+			h := middleware.RequestID(Sampler(tt.rate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if IsTraced(r.Context()) {
+					traced++
+				}
+			})))
+			for i := 0; i < requests; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+
+			want := tt.rate * requests
+			// Allow five standard deviations of a binomial sample.
+			if tol := 5 * math.Sqrt(requests*tt.rate*(1-tt.rate)); math.Abs(float64(traced)-want) > tol {
+				t.Errorf("traced %d of %d requests, want %.0f ± %.0f", traced, requests, want, tol)
+			}
+		})
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		h := middleware.RequestID(Sampler(0.5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(strconv.FormatBool(IsTraced(r.Context()))))
+		})))
+		for i := 0; i < 100; i++ {
+			id := "req-" + strconv.Itoa(i)
+			var first string
+			for n := 0; n < 3; n++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set(middleware.RequestIDHeader, id)
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if n == 0 {
+					first = rec.Body.String()
+				} else if rec.Body.String() != first {
+					t.Fatalf("request %s sampled inconsistently", id)
+				}
+			}
+		}
+	})
+}
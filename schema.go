@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+// Schema is a minimal subset of JSON Schema: type, required, properties,
+// items and enum.
+type Schema struct {
+	Type       string             `json:"type,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []any              `json:"enum,omitempty"`
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]*Schema)
+)
+
+// RegisterSchema sets the schema ValidateSchema checks request bodies
+// against for the route with the given method and pattern.
+func RegisterSchema(method, pattern string, s *Schema) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	schemas[method+" "+pattern] = s
+}
+
+// maxSchemaBodyBytes bounds the request bodies ValidateSchema reads.
+const maxSchemaBodyBytes = 1 << 20
+
+// ValidateSchema checks JSON request bodies against the schema registered
+// for the matched route, answering a 422 listing every failure before the
+// handler runs. The body is left readable for the handler. It must be used
+// on a router where the route pattern is known, e.g. via r.With or inside
+// r.Route.
+func ValidateSchema(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		schemasMu.RLock()
+		s := schemas[r.Method+" "+routePattern(r)]
+		schemasMu.RUnlock()
+		if s == nil || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSchemaBodyBytes+1))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "could not read request body")
+			return
+		}
+		if len(body) > maxSchemaBodyBytes {
+			writeLimitError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			writeError(w, r, http.StatusBadRequest, "request body is not valid JSON")
+			return
+		}
+		if failures := s.validate("$", v); len(failures) > 0 {
+			render.Status(r, http.StatusUnprocessableEntity)
+			render.JSON(w, r, schemaErrorResponse{
+				errorResponse: errorResponse{Error: "request body does not match schema", Status: http.StatusUnprocessableEntity, RequestID: RequestID(r)},
+				Failures:      failures,
+			})
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+type schemaErrorResponse struct {
+	errorResponse
+	Failures []string `json:"failures"`
+}
+
+// validate returns a description of every way v fails s, with path naming
+// the offending value.
+func (s *Schema) validate(path string, v any) []string {
+	var failures []string
+	if s.Type != "" && !jsonTypeIs(v, s.Type) {
+		return []string{fmt.Sprintf("%s: must be of type %s", path, s.Type)}
+	}
+	if len(s.Enum) > 0 && !enumContains(s.Enum, v) {
+		failures = append(failures, fmt.Sprintf("%s: must be one of %s", path, formatEnum(s.Enum)))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, field := range s.Required {
+			if _, ok := v[field]; !ok {
+				failures = append(failures, fmt.Sprintf("%s.%s: is required", path, field))
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fv, ok := v[name]; ok {
+				failures = append(failures, s.Properties[name].validate(path+"."+name, fv)...)
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				failures = append(failures, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	}
+	return failures
+}
+
+func jsonTypeIs(v any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+// enumContains reports whether v, as decoded by encoding/json, equals one of
+// enum's values. Enum values are round-tripped through JSON first, so that
+// schemas written in Go may use ints, structs or typed slices, and compared
+// deeply, since objects and arrays can't be compared with ==.
+func enumContains(enum []any, v any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(normalizeJSON(e), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON returns v as encoding/json would decode its encoding into an
+// any.
+func normalizeJSON(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var n any
+	if err := json.Unmarshal(b, &n); err != nil {
+		return v
+	}
+	return n
+}
+
+func formatEnum(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		b, _ := json.Marshal(e)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestValidateSchema(t *testing.T) {
+	t.Cleanup(func() {
+		schemasMu.Lock()
+		delete(schemas, "POST /widgets")
+		schemasMu.Unlock()
+	})
+	RegisterSchema(http.MethodPost, "/widgets", &Schema{
+		Type:     "object",
+		Required: []string{"name", "size"},
+		Properties: map[string]*Schema{
+			"name":  {Type: "string"},
+			"size":  {Type: "integer", Enum: []any{1, 2, 3}},
+			"tags":  {Type: "array", Items: &Schema{Type: "string"}},
+			"shape": {Enum: []any{map[string]any{"kind": "round"}, []string{"square", "flat"}}},
+		},
+	})
+
+	r := chi.NewRouter()
+	r.With(ValidateSchema).Post("/widgets", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	})
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantFailures []string
+	}{
+		{"valid", `{"name":"gear","size":2,"tags":["a"]}`, http.StatusOK, nil},
+		{"valid object enum", `{"name":"gear","size":1,"shape":{"kind":"round"}}`, http.StatusOK, nil},
+		{"valid array enum", `{"name":"gear","size":1,"shape":["square","flat"]}`, http.StatusOK, nil},
+		{"invalid object enum", `{"name":"gear","size":1,"shape":{"kind":"oval"}}`, http.StatusUnprocessableEntity,
+			[]string{`$.shape: must be one of {"kind":"round"}, ["square","flat"]`}},
+		{"missing fields", `{}`, http.StatusUnprocessableEntity,
+			[]string{"$.name: is required", "$.size: is required"}},
+		{"wrong types", `{"name":7,"size":2.5,"tags":["a",1]}`, http.StatusUnprocessableEntity,
+			[]string{"$.name: must be of type string", "$.size: must be of type integer", "$.tags[1]: must be of type string"}},
+		{"not in enum", `{"name":"gear","size":4}`, http.StatusUnprocessableEntity,
+			[]string{"$.size: must be one of 1, 2, 3"}},
+		{"not JSON", `{`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/widgets", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			switch tt.wantStatus {
+			case http.StatusOK:
+				if rec.Body.String() != tt.body {
+					t.Errorf("handler read %q, want the original body %q", rec.Body.String(), tt.body)
+				}
+			case http.StatusUnprocessableEntity:
+				var resp schemaErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(resp.Failures, tt.wantFailures) {
+					t.Errorf("failures = %q, want %q", resp.Failures, tt.wantFailures)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type lastSequence struct {
+	seq     uint64
+	expires time.Time
+}
+
+// SequenceGuard enforces monotonic request ordering for stateful clients.
+// Each request must carry an X-Sequence header greater than the last one
+// seen from the same client, identified as by KeyByUser: the user
+// Authenticate resolved or else the IP. Headers the client picks itself
+// can't identify it, since anyone could reset or poison another client's
+// sequence. It must run after Authenticate, which validateMiddlewareOrder
+// checks. A missing or malformed header gets a 400 and an out-of-order one
+// a 409. Clients idle for longer than ttl start over.
+func SequenceGuard(ttl time.Duration) func(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	last := make(map[string]lastSequence)
+	lastSweep := time.Now()
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			seq, err := strconv.ParseUint(r.Header.Get("X-Sequence"), 10, 64)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "missing or invalid X-Sequence header")
+				return
+			}
+			client := KeyByUser(r)
+
+			mu.Lock()
+			now := time.Now()
+			if now.Sub(lastSweep) >= ttl {
+				for k, v := range last {
+					if now.After(v.expires) {
+						delete(last, k)
+					}
+				}
+				lastSweep = now
+			}
+			prev, ok := last[client]
+			if ok && now.Before(prev.expires) && seq <= prev.seq {
+				mu.Unlock()
+				writeError(w, r, http.StatusConflict, "X-Sequence must be greater than "+strconv.FormatUint(prev.seq, 10))
+				return
+			}
+			last[client] = lastSequence{seq: seq, expires: now.Add(ttl)}
+			mu.Unlock()
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
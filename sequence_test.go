@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestSequenceGuard(t *testing.T) {
+	creds := map[string]string{"alice": "pw-a", "bob": "pw-b"}
+	h := Authenticate("test", creds)(SequenceGuard(time.Minute)(okHandler))
+
+	tests := []struct {
+		name       string
+		user       string
+		apiKey     string
+		seq        string
+		wantStatus int
+	}{
+		{"first", "alice", "", "1", http.StatusOK},
+		{"in order", "alice", "", "2", http.StatusOK},
+		{"gap allowed", "alice", "", "5", http.StatusOK},
+		{"repeat", "alice", "", "5", http.StatusConflict},
+		{"out of order", "alice", "", "3", http.StatusConflict},
+		{"other user has own sequence", "bob", "", "1", http.StatusOK},
+		{"api key header cannot impersonate", "bob", "alice", "2", http.StatusOK},
+		{"alice unaffected", "alice", "", "6", http.StatusOK},
+		{"missing", "alice", "", "", http.StatusBadRequest},
+		{"malformed", "alice", "", "-1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			req.SetBasicAuth(tt.user, creds[tt.user])
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.seq != "" {
+				req.Header.Set("X-Sequence", tt.seq)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestSequenceGuardTTL(t *testing.T) {
+	h := SequenceGuard(20 * time.Millisecond)(okHandler)
+	send := func(seq string) int {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("X-Sequence", seq)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	send("10")
+	if got := send("1"); got != http.StatusConflict {
+		t.Errorf("before ttl: status = %d, want 409", got)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if got := send("1"); got != http.StatusOK {
+		t.Errorf("after ttl: status = %d, want 200", got)
+	}
+}
+
+func TestSequenceGuardNeedsAuthenticate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("SequenceGuard without Authenticate did not panic")
+		}
+	}()
+	r := chi.NewRouter()
+	r.With(SequenceGuard(time.Minute)).Post("/orders", okHandler)
+	validateMiddlewareOrder(r)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DrainDelay is how long the server keeps accepting requests after a
+// shutdown signal while /readyz reports it unready, giving load balancers
+// time to stop sending traffic.
+var DrainDelay time.Duration
+
+// ShutdownTimeout bounds how long shutdown waits for in-flight requests to
+// finish once the server stops accepting new ones.
+var ShutdownTimeout = 30 * time.Second
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownHooks []func(ctx context.Context) error
+)
+
+// RegisterShutdownHook adds fn to the cleanup run once the server has shut
+// down, e.g. to stop a background goroutine or close a file. Hooks run in
+// reverse order of registration, like deferred calls, and share what is left
+// of ShutdownTimeout through ctx. Errors are logged.
+func RegisterShutdownHook(fn func(ctx context.Context) error) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, fn)
+}
+
+// runShutdownHooks runs the registered hooks, last registered first.
+func runShutdownHooks(ctx context.Context) {
+	shutdownMu.Lock()
+	hooks := shutdownHooks
+	shutdownHooks = nil
+	shutdownMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			log.Printf("shutdown hook: %v", err)
+		}
+	}
+}
+
+// listenFDsStart is the first file descriptor a supervisor passes on under
+// the LISTEN_FDS protocol; 0, 1 and 2 are stdio.
+const listenFDsStart = 3
+
+// listen returns the listener to serve on. When a supervisor such as
+// systemd, or the previous process during a restart, hands over a listening
+// socket through LISTEN_FDS (and LISTEN_PID, if set, names this process),
+// that socket is used, so connections keep being accepted across the swap.
+// Otherwise it binds addr as usual.
+func listen(addr string) (net.Listener, error) {
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return net.Listen("tcp", addr)
+	}
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return net.Listen("tcp", addr)
+	}
+	// Don't hand the socket on again to any child processes.
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(uintptr(listenFDsStart), "listener")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener: %w", err)
+	}
+	log.Printf("serving on inherited listener %s", ln.Addr())
+	return ln, nil
+}
+
+// serve runs srv until it receives SIGINT or SIGTERM, then drains it and
+// runs the shutdown hooks.
+func serve(srv *http.Server) error {
+	ln, err := listen(srv.Addr)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		log.Printf("received %s, draining %d in-flight requests", sig, InFlightCount())
+	}
+
+	draining.Store(true)
+	time.Sleep(DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	err = srv.Shutdown(ctx)
+	runShutdownHooks(ctx)
+	if err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a loopback address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestShutdownHooks(t *testing.T) {
+	logs := captureLog(t)
+	t.Cleanup(func() { draining.Store(false) })
+	// Keep SIGTERM from killing the test binary should it arrive before
+	// serve starts listening for it.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var ran []string
+	srv := &http.Server{Addr: freeAddr(t), Handler: okHandler}
+	RegisterShutdownHook(func(ctx context.Context) error {
+		ran = append(ran, "first")
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("hook context has no deadline")
+		}
+		return nil
+	})
+	RegisterShutdownHook(func(ctx context.Context) error {
+		ran = append(ran, "second")
+		if _, err := http.Get("http://" + srv.Addr); err == nil {
+			t.Error("hook ran before the server stopped accepting requests")
+		}
+		return errors.New("flush failed")
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- serve(srv) }()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("serve = %v", err)
+			}
+			if want := []string{"second", "first"}; !reflect.DeepEqual(ran, want) {
+				t.Errorf("hooks ran %v, want %v", ran, want)
+			}
+			if !strings.Contains(logs.String(), "shutdown hook: flush failed") {
+				t.Errorf("hook error not logged; log: %q", logs.String())
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("serve did not shut down")
+		}
+	}
+}
+
+func TestListenFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		listenFDs string
+		listenPID string
+	}{
+		{"no LISTEN_FDS", "", ""},
+		{"zero descriptors", "0", ""},
+		{"malformed", "two", ""},
+		{"meant for another process", "1", strconv.Itoa(os.Getpid() + 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LISTEN_FDS", tt.listenFDs)
+			t.Setenv("LISTEN_PID", tt.listenPID)
+			addr := freeAddr(t)
+
+			ln, err := listen(addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			if ln.Addr().String() != addr {
+				t.Errorf("listening on %s, want %s", ln.Addr(), addr)
+			}
+			go http.Serve(ln, okHandler)
+			resp, err := http.Get("http://" + addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type serverTimingKey struct{}
+
+type serverTimings struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// AddTiming records a named duration for the Server-Timing header. Timings
+// added after the handler starts writing the response are dropped.
+func AddTiming(ctx context.Context, name string, d time.Duration) {
+	st, ok := ctx.Value(serverTimingKey{}).(*serverTimings)
+	if !ok {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.entries = append(st.entries, formatTiming(name, d))
+}
+
+func formatTiming(name string, d time.Duration) string {
+	return fmt.Sprintf("%s;dur=%.1f", name, float64(d)/float64(time.Millisecond))
+}
+
+// ServerTiming emits a Server-Timing header built from the timings handlers
+// add with AddTiming, plus the total time spent before the response started,
+// so browser dev tools can show a backend breakdown.
+func ServerTiming(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		st := &serverTimings{}
+		tw := &serverTimingWriter{ResponseWriter: w, timings: st, start: time.Now()}
+		ctx := context.WithValue(r.Context(), serverTimingKey{}, st)
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		if !tw.wroteHeader {
+			// The handler wrote nothing, and the server will send the
+			// headers once it returns.
+			tw.setHeader()
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// serverTimingWriter sets the Server-Timing header just before the response
+// headers are sent, which is the last moment it can.
+type serverTimingWriter struct {
+	http.ResponseWriter
+	timings     *serverTimings
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *serverTimingWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.setHeader()
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+// setHeader sets Server-Timing from the timings added so far and the total.
+func (tw *serverTimingWriter) setHeader() {
+	tw.timings.mu.Lock()
+	entries := tw.timings.entries
+	entries = append(entries[:len(entries):len(entries)], formatTiming("total", time.Since(tw.start)))
+	tw.timings.mu.Unlock()
+	tw.Header().Set("Server-Timing", strings.Join(entries, ", "))
+}
+
+func (tw *serverTimingWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *serverTimingWriter) Flush() {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *serverTimingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
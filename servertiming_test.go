@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerTiming(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantNames []string
+		minTotal  float64
+	}{
+		{"timings added", func(w http.ResponseWriter, r *http.Request) {
+			AddTiming(r.Context(), "db", 12*time.Millisecond)
+			AddTiming(r.Context(), "cache", 500*time.Microsecond)
+			w.Write([]byte("ok"))
+		}, []string{"db;dur=12.0", "cache;dur=0.5", "total"}, 0},
+		{"total only", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		}, []string{"total"}, 20},
+		{"empty response", func(w http.ResponseWriter, r *http.Request) {
+			AddTiming(r.Context(), "db", 3*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+		}, []string{"db;dur=3.0", "total"}, 20},
+		{"timing after the response started", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+			AddTiming(r.Context(), "late", time.Millisecond)
+		}, []string{"total"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(ServerTiming(tt.handler))
+			defer srv.Close()
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			entries := strings.Split(resp.Header.Get("Server-Timing"), ", ")
+			total, ok := strings.CutPrefix(entries[len(entries)-1], "total;dur=")
+			if !ok {
+				t.Fatalf("Server-Timing = %q, want it to end with the total", resp.Header.Get("Server-Timing"))
+			}
+			if ms, err := strconv.ParseFloat(total, 64); err != nil || ms < tt.minTotal || ms > 5000 {
+				t.Errorf("total = %q, want at least %vms", total, tt.minTotal)
+			}
+			if got := append(entries[:len(entries)-1], "total"); !reflect.DeepEqual(got, tt.wantNames) {
+				t.Errorf("Server-Timing entries = %q, want %q", got, tt.wantNames)
+			}
+		})
+	}
+}
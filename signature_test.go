@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignResponses(t *testing.T) {
+	secret := []byte("s3cret")
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		flush   bool
+		wantSig string
+	}{
+		// echo -n '{"ok":true}' | openssl dgst -sha256 -hmac s3cret
+		{"known body", http.StatusOK, `{"ok":true}`, false, "543e03a3257ce4cdd34b991bf239f266e18a52dd97f26603483a2f265fc2cfe2"},
+		{"error not signed", http.StatusBadRequest, "bad", false, ""},
+		{"flushed not signed", http.StatusOK, "stream", true, ""},
+		{"oversized not signed", http.StatusOK, strings.Repeat("x", maxSignedBytes+1), false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := SignResponses(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+				if tt.flush {
+					w.(http.Flusher).Flush()
+				}
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := rec.Header().Get("X-Signature"); got != tt.wantSig {
+				t.Errorf("X-Signature = %q, want %q", got, tt.wantSig)
+			}
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("response = %d %.20q, want %d %.20q", rec.Code, rec.Body.String(), tt.status, tt.body)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Now().Unix()
+	sign := func(method, target, body string, ts int64) string {
+		u, _ := url.Parse(target)
+		return hex.EncodeToString(requestMAC(secret, method, u.Path, u.RawQuery, strconv.FormatInt(ts, 10), []byte(body)))
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		ts         int64
+		sig        string
+		wantStatus int
+	}{
+		{"valid", "/hook?id=1", `{"a":1}`, now, sign("POST", "/hook?id=1", `{"a":1}`, now), http.StatusOK},
+		{"tampered body", "/hook?id=1", `{"a":2}`, now, sign("POST", "/hook?id=1", `{"a":1}`, now), http.StatusUnauthorized},
+		{"tampered path", "/other?id=1", `{"a":1}`, now, sign("POST", "/hook?id=1", `{"a":1}`, now), http.StatusUnauthorized},
+		{"tampered query", "/hook?id=2", `{"a":1}`, now, sign("POST", "/hook?id=1", `{"a":1}`, now), http.StatusUnauthorized},
+		{"added query", "/hook?id=1&admin=1", `{"a":1}`, now, sign("POST", "/hook?id=1", `{"a":1}`, now), http.StatusUnauthorized},
+		{"expired", "/hook", "", now - 600, sign("POST", "/hook", "", now-600), http.StatusUnauthorized},
+		{"from the future", "/hook", "", now + 600, sign("POST", "/hook", "", now+600), http.StatusUnauthorized},
+		{"within skew", "/hook", "", now - 30, sign("POST", "/hook", "", now-30), http.StatusOK},
+		{"not hex", "/hook", "", now, "zz", http.StatusUnauthorized},
+		{"oversized", "/hook", strings.Repeat("x", maxSignedBytes+1), now, "", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := VerifySignature(secret, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+			}))
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("X-Signature-Timestamp", strconv.FormatInt(tt.ts, 10))
+			req.Header.Set("X-Signature", tt.sig)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusOK && got != tt.body {
+				t.Errorf("handler read body %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
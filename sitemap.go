@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sitemapURL is one <url> entry of a sitemap.
+type sitemapURL struct {
+	Loc        string  `xml:"loc"`
+	LastMod    string  `xml:"lastmod,omitempty"`
+	ChangeFreq string  `xml:"changefreq,omitempty"`
+	Priority   float64 `xml:"priority,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// SitemapOption sets an optional field of a sitemap entry.
+type SitemapOption func(*sitemapURL)
+
+// LastModified sets when the page last changed.
+func LastModified(t time.Time) SitemapOption {
+	return func(u *sitemapURL) {
+		u.LastMod = t.UTC().Format(time.RFC3339)
+	}
+}
+
+// ChangeFrequency hints how often the page changes: "always", "hourly",
+// "daily", "weekly", "monthly", "yearly" or "never".
+func ChangeFrequency(freq string) SitemapOption {
+	return func(u *sitemapURL) {
+		u.ChangeFreq = freq
+	}
+}
+
+// Priority ranks the page against the site's other pages, from 0 to 1.
+func Priority(p float64) SitemapOption {
+	return func(u *sitemapURL) {
+		u.Priority = p
+	}
+}
+
+var sitemap struct {
+	sync.Mutex
+	urls []sitemapURL
+}
+
+// RegisterSitemapURL adds loc, usually a path such as "/about", to
+// /sitemap.xml. Relative locations are made absolute with the scheme and
+// host each sitemap request arrived on.
+func RegisterSitemapURL(loc string, opts ...SitemapOption) {
+	u := sitemapURL{Loc: loc}
+	for _, opt := range opts {
+		opt(&u)
+	}
+	sitemap.Lock()
+	defer sitemap.Unlock()
+	sitemap.urls = append(sitemap.urls, u)
+}
+
+// sitemapHandler serves the registered URLs as a sitemaps.org XML sitemap.
+func sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	base := &url.URL{Scheme: requestScheme(r), Host: r.Host, Path: "/"}
+
+	sitemap.Lock()
+	set := sitemapURLSet{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  make([]sitemapURL, 0, len(sitemap.urls)),
+	}
+	for _, u := range sitemap.urls {
+		if ref, err := url.Parse(u.Loc); err == nil {
+			u.Loc = base.ResolveReference(ref).String()
+		}
+		set.URLs = append(set.URLs, u)
+	}
+	sitemap.Unlock()
+
+	body, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "could not build sitemap")
+		return
+	}
+	body = append([]byte(xml.Header), body...)
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
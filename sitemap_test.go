@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemapHandler(t *testing.T) {
+	sitemap.Lock()
+	oldURLs := sitemap.urls
+	sitemap.urls = nil
+	sitemap.Unlock()
+	t.Cleanup(func() {
+		sitemap.Lock()
+		sitemap.urls = oldURLs
+		sitemap.Unlock()
+	})
+
+	changed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	RegisterSitemapURL("/", ChangeFrequency("daily"), Priority(1))
+	RegisterSitemapURL("/about", LastModified(changed))
+	RegisterSitemapURL("/search?q=a&page=2")
+	RegisterSitemapURL("https://blog.example.com/", Priority(0.5))
+
+	// The structure of a sitemap per http://www.sitemaps.org/schemas/sitemap/0.9.
+	type url struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+	}
+	type urlset struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []url    `xml:"url"`
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   []url
+	}{
+		{"http", "http://www.example.com/sitemap.xml", []url{
+			{Loc: "http://www.example.com/", ChangeFreq: "daily", Priority: "1"},
+			{Loc: "http://www.example.com/about", LastMod: "2024-03-01T11:00:00Z"},
+			{Loc: "http://www.example.com/search?q=a&page=2"},
+			{Loc: "https://blog.example.com/", Priority: "0.5"},
+		}},
+		{"https", "https://example.org/sitemap.xml", []url{
+			{Loc: "https://example.org/", ChangeFreq: "daily", Priority: "1"},
+			{Loc: "https://example.org/about", LastMod: "2024-03-01T11:00:00Z"},
+			{Loc: "https://example.org/search?q=a&page=2"},
+			{Loc: "https://blog.example.com/", Priority: "0.5"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			sitemapHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if got := rec.Header().Get("Content-Type"); got != "application/xml; charset=utf-8" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			body := rec.Body.String()
+			if !strings.HasPrefix(body, `<?xml version="1.0" encoding="UTF-8"?>`) {
+				t.Errorf("missing XML declaration: %.40q", body)
+			}
+			if !strings.Contains(body, "q=a&amp;page=2") {
+				t.Errorf("& not escaped in %q", body)
+			}
+			var set urlset
+			if err := xml.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+				t.Fatalf("invalid sitemap: %v", err)
+			}
+			if !reflect.DeepEqual(set.URLs, tt.want) {
+				t.Errorf("urls = %+v\nwant %+v", set.URLs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SlashPolicy is the canonical form of paths under TrailingSlash.
+type SlashPolicy int
+
+const (
+	// SlashNever makes "/a/b" canonical and "/a/b/" not.
+	SlashNever SlashPolicy = iota
+	// SlashAlways makes "/a/b/" canonical and "/a/b" not.
+	SlashAlways
+)
+
+// TrailingSlash enforces policy on every path except "/" and those under one
+// of the exempt prefixes. GET and HEAD requests for the non-canonical form
+// are 301-redirected to the canonical one, keeping the query string; other
+// methods get a 404, since redirecting would lose their body.
+//
+// Use it on the router whose routes it covers, before any routes: a
+// middleware added to an inline group only runs once a route has matched,
+// which the non-canonical form never does. Exempt trees served by
+// FileServer, which has its own directory redirects.
+func TrailingSlash(policy SlashPolicy, exempt ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if path == "/" || path == "" || slashExempt(path, exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hasSlash := strings.HasSuffix(path, "/")
+			var canonical string
+			switch {
+			case policy == SlashAlways && !hasSlash:
+				canonical = path + "/"
+			case policy == SlashNever && hasSlash:
+				canonical = strings.TrimRight(path, "/")
+				if canonical == "" {
+					canonical = "/"
+				}
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// "//evil.com/" must not become a redirect to another site.
+			if r.Method != http.MethodGet && r.Method != http.MethodHead || !sameOrigin(r, canonical) {
+				writeError(w, r, http.StatusNotFound, "not found")
+				return
+			}
+			if r.URL.RawQuery != "" {
+				canonical += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, canonical, http.StatusMovedPermanently)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func slashExempt(path string, exempt []string) bool {
+	for _, prefix := range exempt {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
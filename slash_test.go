@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       SlashPolicy
+		method       string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"never: canonical", SlashNever, http.MethodGet, "/users", http.StatusOK, ""},
+		{"never: redirected", SlashNever, http.MethodGet, "/users/", http.StatusMovedPermanently, "/users"},
+		{"never: several slashes", SlashNever, http.MethodGet, "/users//", http.StatusMovedPermanently, "/users"},
+		{"never: query kept", SlashNever, http.MethodHead, "/users/?page=2&sort=name", http.StatusMovedPermanently, "/users?page=2&sort=name"},
+		{"never: POST not redirected", SlashNever, http.MethodPost, "/users/", http.StatusNotFound, ""},
+		{"never: root", SlashNever, http.MethodGet, "/", http.StatusOK, ""},
+		{"never: no open redirect", SlashNever, http.MethodGet, "//evil.com/", http.StatusNotFound, ""},
+		{"always: canonical", SlashAlways, http.MethodGet, "/users/", http.StatusOK, ""},
+		{"always: redirected", SlashAlways, http.MethodGet, "/users?page=2", http.StatusMovedPermanently, "/users/?page=2"},
+		{"always: DELETE not redirected", SlashAlways, http.MethodDelete, "/users", http.StatusNotFound, ""},
+		{"never: exempt tree", SlashNever, http.MethodGet, "/files/docs/", http.StatusOK, ""},
+		{"always: exempt tree", SlashAlways, http.MethodGet, "/files/report.pdf", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := TrailingSlash(tt.policy, "/files/")(okHandler)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
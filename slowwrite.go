@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// WriteTimeout gives every Write to the client its own deadline of d, so a
+// client that reads the response very slowly, or not at all, cannot hold a
+// handler goroutine for longer than d per chunk. Once a write times out the
+// connection is unusable; the failure is logged and later writes fail fast.
+//
+// It relies on http.ResponseController, so the writers between this
+// middleware and the server must support Unwrap.
+func WriteTimeout(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			dw := &deadlineWriter{ResponseWriter: w, rc: http.NewResponseController(w), timeout: d, r: r}
+			defer dw.rc.SetWriteDeadline(time.Time{})
+			next.ServeHTTP(dw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+type deadlineWriter struct {
+	http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+	r       *http.Request
+	failed  bool
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	if err := dw.rc.SetWriteDeadline(time.Now().Add(dw.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return 0, err
+	}
+	n, err := dw.ResponseWriter.Write(p)
+	if err != nil && !dw.failed && errors.Is(err, os.ErrDeadlineExceeded) {
+		dw.failed = true
+		slog.Error("client too slow, aborting response",
+			"request_id", RequestID(dw.r),
+			"path", dw.r.URL.Path,
+			"timeout", dw.timeout,
+			"err", err)
+	}
+	return n, err
+}
+
+func (dw *deadlineWriter) Flush() {
+	dw.rc.SetWriteDeadline(time.Now().Add(dw.timeout))
+	dw.rc.Flush()
+}
+
+func (dw *deadlineWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
+
+// MaxResponseDuration caps the time a response may take as a whole, for
+// long downloads and streams that are allowed to outlast the request
+// timeout but not to run forever. The write deadline is set to d from the
+// start of the request and the request context is cancelled then too, so
+// a handler stuck waiting stops as well as one stuck writing. Once the cap
+// is hit the connection is closed and a warning is logged.
+//
+// Don't combine it with WriteTimeout, which moves the same deadline on
+// every write.
+func MaxResponseDuration(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			deadline := time.Now().Add(d)
+			rc := http.NewResponseController(w)
+			if err := rc.SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				slog.Warn("could not set response deadline", "request_id", RequestID(r), "err", err)
+			}
+			defer func() {
+				// Leave an expired deadline in place, so the connection is
+				// closed rather than reused.
+				if time.Now().Before(deadline) {
+					rc.SetWriteDeadline(time.Time{})
+				}
+			}()
+
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			cw := &capWriter{ResponseWriter: w, r: r, max: d}
+			next.ServeHTTP(cw, r)
+			if !cw.warned && ctx.Err() == context.DeadlineExceeded {
+				cw.warn(ctx.Err())
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// capWriter logs the first write that fails because MaxResponseDuration's
+// deadline has passed.
+type capWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	max    time.Duration
+	warned bool
+}
+
+func (cw *capWriter) Write(p []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(p)
+	if err != nil && !cw.warned && errors.Is(err, os.ErrDeadlineExceeded) {
+		cw.warn(err)
+	}
+	return n, err
+}
+
+func (cw *capWriter) warn(err error) {
+	cw.warned = true
+	slog.Warn("response took too long, closing connection",
+		"request_id", RequestID(cw.r),
+		"path", cw.r.URL.Path,
+		"max", cw.max,
+		"err", err)
+}
+
+func (cw *capWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *capWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
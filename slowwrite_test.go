@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamUntilError writes chunks to w until a write fails or limit passes,
+// and returns the error.
+func streamUntilError(w http.ResponseWriter, limit time.Duration) error {
+	chunk := make([]byte, 64<<10)
+	stop := time.Now().Add(limit)
+	for time.Now().Before(stop) {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSlowly sends a GET for srv's root over a raw connection and then, if
+// read is false, never reads the response.
+func getSlowly(t *testing.T, srv *httptest.Server, read bool) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if read {
+		go func() {
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err == nil {
+				io.Copy(io.Discard, resp.Body)
+			}
+		}()
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		read        bool
+		wantTimeout bool
+	}{
+		{"slow reader", false, true},
+		{"fast reader", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			result := make(chan error, 1)
+			srv := httptest.NewServer(WriteTimeout(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				result <- streamUntilError(w, 300*time.Millisecond)
+			})))
+			defer srv.Close()
+			getSlowly(t, srv, tt.read)
+
+			select {
+			case err := <-result:
+				if got := errors.Is(err, os.ErrDeadlineExceeded); got != tt.wantTimeout {
+					t.Errorf("write error = %v, want timeout %v", err, tt.wantTimeout)
+				}
+				if got := strings.Contains(logs.String(), "client too slow"); got != tt.wantTimeout {
+					t.Errorf("logged abort = %v, want %v", got, tt.wantTimeout)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler is still writing to a client that isn't reading")
+			}
+		})
+	}
+}
+
+func TestMaxResponseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  time.Duration
+		wait    bool
+		wantCut bool
+	}{
+		{"stream within the cap", 20 * time.Millisecond, false, false},
+		{"stream past the cap", 2 * time.Second, false, true},
+		{"handler waiting past the cap", 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			result := make(chan error, 1)
+			srv := httptest.NewServer(MaxResponseDuration(100 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.wait {
+					select {
+					case <-r.Context().Done():
+						result <- r.Context().Err()
+					case <-time.After(2 * time.Second):
+						result <- nil
+					}
+					return
+				}
+				result <- streamUntilError(w, tt.stream)
+			})))
+			defer srv.Close()
+			start := time.Now()
+			getSlowly(t, srv, true)
+
+			select {
+			case err := <-result:
+				if cut := err != nil; cut != tt.wantCut {
+					t.Errorf("stream ended with %v, want cut off %v", err, tt.wantCut)
+				}
+				if tt.wantCut && time.Since(start) > time.Second {
+					t.Errorf("cut off after %s, want about 100ms", time.Since(start))
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream ran past the cap")
+			}
+			srv.Close()
+			if got := strings.Contains(logs.String(), "response took too long"); got != tt.wantCut {
+				t.Errorf("logged warning = %v, want %v; log: %q", got, tt.wantCut, logs.String())
+			}
+		})
+	}
+}
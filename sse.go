@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SSEWriter streams Server-Sent Events to a client.
+type SSEWriter struct {
+	w   http.ResponseWriter
+	f   http.Flusher
+	ctx context.Context
+}
+
+// NewSSEWriter sends the event-stream headers and returns a writer for the
+// events. It fails if w cannot be flushed, since events would then sit in a
+// buffer instead of reaching the client.
+func NewSSEWriter(w http.ResponseWriter, r *http.Request) (*SSEWriter, error) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("sse: response writer does not support flushing")
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	return &SSEWriter{w: w, f: f, ctx: r.Context()}, nil
+}
+
+// Send writes one event and flushes it to the client. An empty event name
+// sends an unnamed "message" event. Once the client has gone away Send
+// returns the context's error.
+func (s *SSEWriter) Send(event, data string) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	if _, err := s.w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	s.f.Flush()
+	return nil
+}
+
+// eventsHandler is an example event stream sending the time every second
+// until the client disconnects.
+func eventsHandler(w http.ResponseWriter, r *http.Request) error {
+	sse, err := NewSSEWriter(w, r)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case t := <-ticker.C:
+			if err := sse.Send("tick", t.Format(time.RFC3339)); err != nil {
+				// The client has gone away; there is no one to report to.
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSSEWriter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse, err := NewSSEWriter(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		sse.Send("greeting", "hello")
+		sse.Send("", "two\nlines")
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	for k, want := range map[string]string{
+		"Content-Type":  "text/event-stream",
+		"Cache-Control": "no-cache",
+	} {
+		if got := resp.Header.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{"named event", []string{"event: greeting", "data: hello"}},
+		{"multi-line message", []string{"data: two", "data: lines"}},
+	}
+	sc := bufio.NewScanner(resp.Body)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for sc.Scan() && sc.Text() != "" {
+				got = append(got, sc.Text())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.lines, "\n") {
+				t.Errorf("event = %q, want %q", got, tt.lines)
+			}
+		})
+	}
+}
+
+func TestSSEWriterStopsOnDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	sse, err := NewSSEWriter(rec, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sse.Send("", "before"); err != nil {
+		t.Fatalf("Send before disconnect: %v", err)
+	}
+	cancel()
+	if err := sse.Send("", "after"); err != context.Canceled {
+		t.Errorf("Send after disconnect = %v, want context.Canceled", err)
+	}
+	if strings.Contains(rec.Body.String(), "after") {
+		t.Errorf("body = %q, want nothing sent after disconnect", rec.Body.String())
+	}
+}
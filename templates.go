@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-chi/render"
+)
+
+var templates atomic.Pointer[template.Template]
+
+// SetTemplates registers the template set RenderTemplate renders from.
+func SetTemplates(t *template.Template) {
+	templates.Store(t)
+}
+
+// RenderTemplate renders the named template from the set registered with
+// SetTemplates as an HTML page, with the status set by render.Status or 200.
+// The page is rendered into a buffer first, so a template that fails halfway
+// doesn't leave the client with half a page; the error is returned, wrapped
+// in a 500 HTTPError, for the Handler to report instead.
+func RenderTemplate(w http.ResponseWriter, r *http.Request, name string, data any) error {
+	t := templates.Load()
+	if t == nil {
+		return &HTTPError{Status: http.StatusInternalServerError, Message: "could not render page", Err: errors.New("no templates registered")}
+	}
+
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return &HTTPError{Status: http.StatusInternalServerError, Message: "could not render page", Err: err}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/render"
+)
+
+type pageData struct {
+	Name string
+	fail bool
+}
+
+func (p pageData) Footer() (string, error) {
+	if p.fail {
+		return "", errors.New("footer unavailable")
+	}
+	return "bye", nil
+}
+
+func TestRenderTemplate(t *testing.T) {
+	old := templates.Load()
+	t.Cleanup(func() { templates.Store(old) })
+
+	set := template.Must(template.New("page").Parse(`<h1>Hello {{.Name}}</h1>{{.Footer}}`))
+	tests := []struct {
+		name       string
+		set        *template.Template
+		template   string
+		data       pageData
+		status     int
+		wantStatus int
+		wantType   string
+		wantBody   string
+		notInBody  string
+	}{
+		{"renders", set, "page", pageData{Name: "<ann>"}, 0, http.StatusOK, "text/html; charset=utf-8", "<h1>Hello &lt;ann&gt;</h1>bye", ""},
+		{"with status", set, "page", pageData{Name: "ann"}, http.StatusCreated, http.StatusCreated, "text/html; charset=utf-8", "<h1>Hello ann</h1>bye", ""},
+		{"fails halfway", set, "page", pageData{Name: "ann", fail: true}, 0, http.StatusInternalServerError, "application/json", `"could not render page"`, "Hello"},
+		{"unknown template", set, "missing", pageData{}, 0, http.StatusInternalServerError, "application/json", `"could not render page"`, ""},
+		{"no templates registered", nil, "page", pageData{}, 0, http.StatusInternalServerError, "application/json", `"could not render page"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetTemplates(tt.set)
+			h := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				if tt.status != 0 {
+					render.Status(r, tt.status)
+				}
+				return RenderTemplate(w, r, tt.template, tt.data)
+			})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body, tt.wantBody)
+			}
+			if tt.notInBody != "" && strings.Contains(rec.Body.String(), tt.notInBody) {
+				t.Errorf("body = %q, partial page leaked", rec.Body)
+			}
+		})
+	}
+}
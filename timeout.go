@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// WithTimeout runs h with a context that is cancelled after d. If h has not
+// returned by then, the request fails with a 504 HTTPError and anything h
+// writes afterwards is discarded. h should watch r.Context() so that its
+// goroutine exits promptly once the deadline passes.
+func WithTimeout(d time.Duration, h Handler) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		req := r.WithContext(ctx)
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan error, 1)
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			done <- h(tw, req)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case err := <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			if err != nil {
+				return err
+			}
+			for k, v := range tw.header {
+				w.Header()[k] = v
+			}
+			if tw.status != 0 {
+				w.WriteHeader(tw.status)
+			}
+			_, err = w.Write(tw.buf.Bytes())
+			return err
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			return &HTTPError{Status: http.StatusGatewayTimeout, Message: "handler timed out", Err: ctx.Err()}
+		}
+	}
+}
+
+// timeoutWriter buffers a response produced on another goroutine, so that
+// only one of the handler and WithTimeout ever writes to the client.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.status == 0 && !tw.timedOut {
+		tw.status = code
+	}
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.buf.Write(p)
+}
+
+type requestTimeoutKey struct{}
+
+// requestTimeout is what Streaming needs to lift Timeout's deadline.
+type requestTimeout struct {
+	// parent is the request's context from before Timeout.
+	parent    context.Context
+	streaming atomic.Bool
+}
+
+// Timeout gives each request a deadline d after it starts and, if the
+// handler hasn't written anything by the time it returns, answers with a
+// 504. Once the deadline passes the context's Err is
+// context.DeadlineExceeded, so timeouts aren't mistaken for clients that
+// went away, and its cause says which timeout it was. Routes that stream,
+// such as SSE or large downloads, opt out with Streaming.
+func Timeout(d time.Duration) func(next http.Handler) http.Handler {
+	cause := fmt.Errorf("request timeout of %s: %w", d, context.DeadlineExceeded)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeoutCause(r.Context(), d, cause)
+			defer cancel()
+			rt := &requestTimeout{parent: r.Context()}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			r = r.WithContext(context.WithValue(ctx, requestTimeoutKey{}, rt))
+			next.ServeHTTP(ww, r)
+
+			if !rt.streaming.Load() && errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				writeError(ww, r, http.StatusGatewayTimeout, "request timed out")
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Streaming exempts the routes it is used on from Timeout:
+//
+//	r.With(Streaming).Get("/events", eventsHandler)
+//
+// Which route a request takes is only known once it has been routed, after
+// Timeout has set the deadline, so Streaming hands the handler a context
+// without it, which is still cancelled when the client goes away.
+func Streaming(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if rt, ok := r.Context().Value(requestTimeoutKey{}).(*requestTimeout); ok {
+			rt.streaming.Store(true)
+			ctx, cancel := context.WithCancelCause(context.WithoutCancel(r.Context()))
+			defer cancel(nil)
+			stop := context.AfterFunc(rt.parent, func() { cancel(context.Cause(rt.parent)) })
+			defer stop()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// WarnNearDeadline logs a warning when a request with a deadline, such as one
+// set by Timeout or middleware.Timeout, used more than fraction of the time it had left
+// on arrival. It must run after the middleware that sets the deadline, and
+// helps spot near misses before they become timeouts.
+func WarnNearDeadline(fraction float64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			deadline, ok := r.Context().Deadline()
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			budget := deadline.Sub(start)
+
+			next.ServeHTTP(w, r)
+
+			if took := time.Since(start); float64(took) > fraction*float64(budget) {
+				log.Printf("[%s] %s %s took %s of its %s deadline (%.0f%%)",
+					RequestID(r), r.Method, r.URL.Path,
+					took.Round(time.Millisecond), budget.Round(time.Millisecond),
+					100*float64(took)/float64(budget))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// captureLog redirects the standard logger into a buffer for the rest of
+// the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		work       time.Duration
+		wantStatus int
+		wantBody   string
+	}{
+		{"timely", 0, http.StatusCreated, "done"},
+		{"timed out", time.Second, http.StatusGatewayTimeout, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := WithTimeout(50*time.Millisecond, func(w http.ResponseWriter, r *http.Request) error {
+				select {
+				case <-time.After(tt.work):
+				case <-r.Context().Done():
+					return r.Context().Err()
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("done"))
+				return nil
+			})
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if took := time.Since(start); took > 500*time.Millisecond {
+				t.Errorf("took %s, want the timeout to cut it short", took)
+			}
+		})
+	}
+}
+
+func TestWarnNearDeadline(t *testing.T) {
+	tests := []struct {
+		name       string
+		work       time.Duration
+		viaTimeout bool
+		wantWarn   bool
+	}{
+		{"fast", 0, false, false},
+		{"slow", 80 * time.Millisecond, false, true},
+		{"fast under Timeout", 0, true, false},
+		{"slow under Timeout", 80 * time.Millisecond, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			var h http.Handler = WarnNearDeadline(0.5)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.work)
+				w.Write([]byte("ok"))
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+			if tt.viaTimeout {
+				h = Timeout(100 * time.Millisecond)(h)
+			} else {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got := strings.Contains(logs.String(), "GET /slow took"); got != tt.wantWarn {
+				t.Errorf("warned = %v, want %v; log: %q", got, tt.wantWarn, logs.String())
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	logs := captureLog(t)
+	type observed struct {
+		events      int
+		err         error
+		cause       error
+		hasDeadline bool
+	}
+	var got observed
+	// stream sends an event every 20ms for 150ms, three times the timeout.
+	stream := func(w http.ResponseWriter, r *http.Request) {
+		_, got.hasDeadline = r.Context().Deadline()
+		sse, err := NewSSEWriter(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for stop := time.Now().Add(150 * time.Millisecond); time.Now().Before(stop); {
+			if err := sse.Send("tick", "x"); err != nil {
+				break
+			}
+			got.events++
+			time.Sleep(20 * time.Millisecond)
+		}
+		got.err = r.Context().Err()
+	}
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		_, got.hasDeadline = r.Context().Deadline()
+		<-r.Context().Done()
+		got.err, got.cause = r.Context().Err(), context.Cause(r.Context())
+	}
+
+	r := chi.NewRouter()
+	r.Use(LogClientClosed, Timeout(50*time.Millisecond))
+	r.Get("/fast", okHandler.ServeHTTP)
+	r.Get("/slow", slow)
+	r.With(Streaming).Get("/events", stream)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		want       observed
+		wantCause  string
+	}{
+		{"fast", "/fast", http.StatusOK, observed{}, ""},
+		{"slow", "/slow", http.StatusGatewayTimeout, observed{err: context.DeadlineExceeded, hasDeadline: true}, "request timeout of 50ms"},
+		{"streaming", "/events", http.StatusOK, observed{events: 8}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = observed{}
+			logs.Reset()
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got.err != tt.want.err {
+				t.Errorf("ctx.Err() = %v, want %v", got.err, tt.want.err)
+			}
+			if got.hasDeadline != tt.want.hasDeadline {
+				t.Errorf("has deadline = %v, want %v", got.hasDeadline, tt.want.hasDeadline)
+			}
+			if tt.wantCause != "" && (got.cause == nil || !strings.Contains(got.cause.Error(), tt.wantCause)) {
+				t.Errorf("cause = %v, want %q", got.cause, tt.wantCause)
+			}
+			if got.events < tt.want.events-2 {
+				t.Errorf("sent %d events, want about %d", got.events, tt.want.events)
+			}
+			if strings.Contains(logs.String(), "Client Closed Request") {
+				t.Errorf("logged as a client disconnect: %q", logs.String())
+			}
+		})
+	}
+
+	t.Run("streaming client goes away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(30*time.Millisecond, cancel)
+		got = observed{}
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx))
+		if got.err != context.Canceled {
+			t.Errorf("ctx.Err() = %v, want context.Canceled", got.err)
+		}
+		if got.events > 3 {
+			t.Errorf("sent %d events after the client left", got.events)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxUnixSeconds is the last second of year 9999.
+const maxUnixSeconds = 253402300799
+
+// ParseTime reads a timestamp from the query parameter param or, when the
+// query doesn't have it, from the header of the same name. RFC 3339 (with
+// optional fractional seconds) and Unix time in seconds (optionally with a
+// fraction, e.g. "1700000000.25") are accepted, and the result is in UTC.
+// A missing value gives the zero time and no error; a malformed one gives a
+// 400 HTTPError naming param.
+func ParseTime(r *http.Request, param string) (time.Time, error) {
+	v := r.URL.Query().Get(param)
+	if v == "" {
+		v = r.Header.Get(param)
+	}
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t.UTC(), nil
+	}
+	// The bound, the end of year 9999, also rules out NaN and infinities.
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && math.Abs(secs) <= maxUnixSeconds {
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	}
+	return time.Time{}, &HTTPError{
+		Status:  http.StatusBadRequest,
+		Message: param + " must be an RFC 3339 timestamp or Unix time in seconds",
+	}
+}
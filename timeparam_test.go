@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		header  string
+		want    time.Time
+		wantErr bool
+	}{
+		{"RFC 3339", "2024-03-01T12:00:00Z", "", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"RFC 3339 with offset", "2024-03-01T13:30:00%2B01:30", "", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"RFC 3339 with fraction", "2024-03-01T12:00:00.5Z", "", time.Date(2024, 3, 1, 12, 0, 0, 5e8, time.UTC), false},
+		{"Unix seconds", "1709294400", "", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"Unix with fraction", "1709294400.25", "", time.Date(2024, 3, 1, 12, 0, 0, 25e7, time.UTC), false},
+		{"before the epoch", "-86400", "", time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{"from the header", "", "2024-03-01T12:00:00Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"query wins", "1709294400", "2000-01-01T00:00:00Z", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), false},
+		{"missing", "", "", time.Time{}, false},
+		{"date only", "2024-03-01", "", time.Time{}, true},
+		{"garbage", "yesterday", "", time.Time{}, true},
+		{"not a number", "NaN", "", time.Time{}, true},
+		{"out of range", "1e20", "", time.Time{}, true},
+		{"bad header", "", "soon", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?since="+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("since", tt.header)
+			}
+			got, err := ParseTime(req, "since")
+			if tt.wantErr {
+				var herr *HTTPError
+				if !errors.As(err, &herr) || herr.Status != http.StatusBadRequest {
+					t.Errorf("err = %v, want a 400 HTTPError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("ParseTime = %v, want %v in UTC", got, tt.want)
+			}
+		})
+	}
+}
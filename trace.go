@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type traceKey struct{}
+
+type spanRecord struct {
+	Name       string    `json:"name"`
+	Start      time.Time `json:"start"`
+	DurationMS float64   `json:"duration_ms"`
+}
+
+type traceRecord struct {
+	RequestID  string       `json:"request_id"`
+	Method     string       `json:"method"`
+	Route      string       `json:"route"`
+	Start      time.Time    `json:"start"`
+	DurationMS float64      `json:"duration_ms"`
+	Status     int          `json:"status"`
+	Spans      []spanRecord `json:"spans,omitempty"`
+}
+
+// trace collects the sub-spans recorded while serving one request.
+type trace struct {
+	mu    sync.Mutex
+	spans []spanRecord
+}
+
+// StartSpan starts a sub-span of the current request's trace and returns a
+// function that ends it:
+//
+//	defer StartSpan(r.Context(), "load user")()
+//
+// It does nothing when tracing is not enabled.
+func StartSpan(ctx context.Context, name string) func() {
+	t, ok := ctx.Value(traceKey{}).(*trace)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.spans = append(t.spans, spanRecord{Name: name, Start: start, DurationMS: msSince(start)})
+	}
+}
+
+// TraceWriter appends one JSON line per request, with its sub-spans, to a
+// file. Writes are buffered and flushed every second and on Close.
+type TraceWriter struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	done chan struct{}
+}
+
+// NewTraceWriter opens (or creates) path for appending trace records.
+func NewTraceWriter(path string) (*TraceWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	tw := &TraceWriter{f: f, w: bufio.NewWriter(f), done: make(chan struct{})}
+	go tw.flushLoop()
+	return tw, nil
+}
+
+// Middleware starts the root span for each request and writes the finished
+// trace once the response is done.
+func (tw *TraceWriter) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		t := &trace{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		defer func() {
+			t.mu.Lock()
+			rec := traceRecord{
+				RequestID:  RequestID(r),
+				Method:     r.Method,
+				Route:      routePattern(r),
+				Start:      start,
+				DurationMS: msSince(start),
+				Status:     ww.Status(),
+				Spans:      t.spans,
+			}
+			t.mu.Unlock()
+			tw.write(rec)
+		}()
+
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), traceKey{}, t)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (tw *TraceWriter) write(rec traceRecord) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("trace: %v", err)
+		return
+	}
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.w.Write(append(b, '\n'))
+}
+
+func (tw *TraceWriter) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			tw.mu.Lock()
+			if err := tw.w.Flush(); err != nil {
+				log.Printf("trace: %v", err)
+			}
+			tw.mu.Unlock()
+		case <-tw.done:
+			return
+		}
+	}
+}
+
+// Close flushes any buffered records and closes the file.
+func (tw *TraceWriter) Close() error {
+	close(tw.done)
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if err := tw.w.Flush(); err != nil {
+		tw.f.Close()
+		return err
+	}
+	return tw.f.Close()
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t)) / float64(time.Millisecond)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// Tx is the part of a database transaction Transactional needs; *sql.Tx
+// satisfies it.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+type txKey struct{}
+
+// maxTxResponseBytes is how much of a response Transactional holds back
+// until the transaction has committed.
+const maxTxResponseBytes = 1 << 20
+
+// Transactional runs a Handler inside a transaction started with begin,
+// e.g. a wrapper around db.BeginTx. The handler gets the transaction from
+// TxFromContext. It is committed if the handler returns nil, and rolled back
+// if it returns an error or panics. The response is held back until the
+// commit, so that a failed commit can still be reported as a 500 rather than
+// after a success response; only responses over 1MB start going out first.
+func Transactional(begin func(ctx context.Context) (Tx, error)) func(next Handler) Handler {
+	return func(next Handler) Handler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			tx, err := begin(r.Context())
+			if err != nil {
+				return &HTTPError{Status: http.StatusInternalServerError, Message: "could not begin transaction", Err: err}
+			}
+			defer func() {
+				if p := recover(); p != nil {
+					rollback(r, tx)
+					panic(p)
+				}
+			}()
+
+			before := w.Header().Clone()
+			bw := newBufferedWriter(w, maxTxResponseBytes)
+			ctx := context.WithValue(r.Context(), txKey{}, tx)
+			if err := next(bw, r.WithContext(ctx)); err != nil {
+				rollback(r, tx)
+				if bw.Buffered() {
+					discardResponse(bw, before)
+				}
+				return err
+			}
+
+			if err := tx.Commit(); err != nil {
+				if !bw.Buffered() {
+					log.Printf("[%s] commit failed after the response started: %v", RequestID(r), err)
+					return nil
+				}
+				discardResponse(bw, before)
+				return &HTTPError{Status: http.StatusInternalServerError, Message: "could not commit transaction", Err: err}
+			}
+			return bw.release()
+		}
+	}
+}
+
+// TxFromContext returns the transaction Transactional started for the
+// request.
+func TxFromContext(ctx context.Context) (Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(Tx)
+	return tx, ok
+}
+
+// discardResponse throws away the response held in bw, headers included,
+// putting back the headers set before the handler ran. A rolled-back
+// request's Content-Length or cookies have no place on the error that
+// replaces its response.
+func discardResponse(bw *bufferedWriter, before http.Header) {
+	bw.Reset()
+	h := bw.Header()
+	for k := range h {
+		delete(h, k)
+	}
+	for k, v := range before {
+		h[k] = v
+	}
+}
+
+func rollback(r *http.Request, tx Tx) {
+	if err := tx.Rollback(); err != nil {
+		log.Printf("[%s] rollback failed: %v", RequestID(r), err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type fakeTx struct {
+	commitErr  error
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.committed = true
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestTransactional(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name         string
+		beginErr     error
+		commitErr    error
+		handler      Handler
+		wantStatus   int
+		wantBody     string
+		wantCommit   bool
+		wantRollback bool
+		wantPanic    bool
+	}{
+		{"success commits", nil, nil, func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+			return nil
+		}, http.StatusCreated, "created", true, false, false},
+		{"error rolls back", nil, nil, func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Content-Type", "text/plain")
+			http.SetCookie(w, &http.Cookie{Name: "order", Value: "42"})
+			w.Write([]byte("half a response"))
+			return &HTTPError{Status: http.StatusConflict, Message: "already exists"}
+		}, http.StatusConflict, `"error":"already exists"`, false, true, false},
+		{"panic rolls back", nil, nil, func(w http.ResponseWriter, r *http.Request) error {
+			panic("boom")
+		}, 0, "", false, true, true},
+		{"commit fails", nil, errors.New("serialization failure"), func(w http.ResponseWriter, r *http.Request) error {
+			w.Header().Set("Content-Length", "4")
+			http.SetCookie(w, &http.Cookie{Name: "order", Value: "42"})
+			w.Write([]byte("done"))
+			return nil
+		}, http.StatusInternalServerError, `"error":"could not commit transaction"`, true, false, false},
+		{"begin fails", errors.New("pool exhausted"), nil, func(w http.ResponseWriter, r *http.Request) error {
+			t.Error("handler ran without a transaction")
+			return nil
+		}, http.StatusInternalServerError, `"error":"could not begin transaction"`, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTx{commitErr: tt.commitErr}
+			begin := func(ctx context.Context) (Tx, error) {
+				if tt.beginErr != nil {
+					return nil, tt.beginErr
+				}
+				return tx, nil
+			}
+			outer := func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Request-Id", "abc")
+					next.ServeHTTP(w, r)
+				})
+			}
+			h := Transactional(begin)(func(w http.ResponseWriter, r *http.Request) error {
+				if got, ok := TxFromContext(r.Context()); !ok || got != tx {
+					t.Error("handler didn't get the transaction")
+				}
+				return tt.handler(w, r)
+			})
+			rec := httptest.NewRecorder()
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != tt.wantPanic {
+						t.Errorf("panic = %v, want panic %v", p, tt.wantPanic)
+					}
+				}()
+				outer(h).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+			}()
+
+			if tx.committed != tt.wantCommit || tx.rolledBack != tt.wantRollback {
+				t.Errorf("committed %v, rolled back %v; want %v, %v", tx.committed, tx.rolledBack, tt.wantCommit, tt.wantRollback)
+			}
+			if tt.wantPanic {
+				return
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) || strings.Contains(rec.Body.String(), "half") {
+				t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+			}
+			if got := rec.Header().Get("X-Request-Id"); got != "abc" {
+				t.Errorf("X-Request-Id = %q, want the outer middleware's abc", got)
+			}
+			if rec.Code >= 400 {
+				// The failed request's own headers are gone.
+				if got := rec.Header().Values("Set-Cookie"); len(got) != 0 {
+					t.Errorf("Set-Cookie = %q on the error response", got)
+				}
+				if got := rec.Header().Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				if got := rec.Header().Get("Content-Length"); got != "" && got != strconv.Itoa(rec.Body.Len()) {
+					t.Errorf("Content-Length = %s for a %d byte body", got, rec.Body.Len())
+				}
+			}
+		})
+	}
+}
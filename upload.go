@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+type multipartLimitsKey struct{}
+
+type multipartLimits struct {
+	maxBytes int64
+	maxParts int
+}
+
+// EnforceMultipart bounds multipart uploads: requests declaring more than
+// maxBytes are refused with a 413 before any parsing, the body is cut off at
+// maxBytes in case the declaration lied, and readers obtained through
+// multipartReader stop with a 400 after maxParts parts.
+func EnforceMultipart(maxBytes int64, maxParts int) func(next http.Handler) http.Handler {
+	limits := multipartLimits{maxBytes: maxBytes, maxParts: maxParts}
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				writeLimitError(w, r, http.StatusRequestEntityTooLarge, "upload too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			ctx := context.WithValue(r.Context(), multipartLimitsKey{}, limits)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// partLimitedReader is a multipart.Reader that refuses to go past a maximum
+// number of parts.
+type partLimitedReader struct {
+	*multipart.Reader
+	remaining int
+}
+
+func (pr *partLimitedReader) NextPart() (*multipart.Part, error) {
+	part, err := pr.Reader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	if pr.remaining == 0 {
+		part.Close()
+		return nil, &HTTPError{Status: http.StatusBadRequest, Message: "too many parts in upload"}
+	}
+	pr.remaining--
+	return part, nil
+}
+
+// multipartReader returns a streaming reader over r's multipart body, which
+// honours the part limit set by EnforceMultipart, if any.
+func multipartReader(r *http.Request) (*partLimitedReader, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, &HTTPError{Status: http.StatusBadRequest, Message: "expected a multipart body", Err: err}
+	}
+	remaining := -1
+	if limits, ok := r.Context().Value(multipartLimitsKey{}).(multipartLimits); ok {
+		remaining = limits.maxParts
+	}
+	return &partLimitedReader{Reader: mr, remaining: remaining}, nil
+}
+
+// uploadProgressInterval is how often progressReader logs how much of an
+// upload has arrived.
+var uploadProgressInterval = 5 * time.Second
+
+// progressReader counts the bytes read from an upload, logs the count every
+// uploadProgressInterval, and fails once ctx is done.
+type progressReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	r       *http.Request
+	n       int64
+	start   time.Time
+	lastLog time.Time
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := pr.ReadCloser.Read(p)
+	pr.n += int64(n)
+	if now := time.Now(); now.Sub(pr.lastLog) >= uploadProgressInterval {
+		pr.lastLog = now
+		slog.Info("upload progress",
+			"request_id", RequestID(pr.r),
+			"bytes", pr.n,
+			"elapsed", now.Sub(pr.start).Round(time.Millisecond))
+	}
+	return n, err
+}
+
+type uploadedFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// uploadHandler streams every file part of a multipart upload into dir,
+// without holding whole files in memory, logging its progress as it goes.
+// An upload still incomplete after timeout, e.g. because the client stalled,
+// is aborted with a 408.
+func uploadHandler(dir string, timeout time.Duration) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		// The read deadline unblocks a Read that is waiting on a stalled
+		// client; the context catches the deadline between reads.
+		deadline, _ := ctx.Deadline()
+		http.NewResponseController(w).SetReadDeadline(deadline)
+		now := time.Now()
+		r.Body = &progressReader{ReadCloser: r.Body, ctx: ctx, r: r, start: now, lastLog: now}
+
+		mr, err := multipartReader(r)
+		if err != nil {
+			return err
+		}
+
+		saved := []uploadedFile{}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return uploadError(err)
+			}
+			if part.FileName() == "" {
+				continue
+			}
+
+			name := sanitizeFilename(filepath.Base(part.FileName()))
+			size, err := saveUpload(filepath.Join(dir, name), part)
+			if err != nil {
+				return uploadError(err)
+			}
+			saved = append(saved, uploadedFile{Name: name, Size: size})
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, map[string]any{"files": saved})
+		return nil
+	}
+}
+
+// saveUpload copies src into a new file at path. A partially written file
+// is removed.
+func saveUpload(path string, src io.Reader) (int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return n, nil
+}
+
+// uploadError maps failures while reading an upload to the status the
+// client should see.
+func uploadError(err error) error {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: "upload too large", Err: err}
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return &HTTPError{Status: http.StatusRequestTimeout, Message: "upload timed out", Err: err}
+	case errors.Is(err, fs.ErrExist):
+		return &HTTPError{Status: http.StatusConflict, Message: "a file with that name already exists", Err: err}
+	}
+	var herr *HTTPError
+	if errors.As(err, &herr) {
+		return err
+	}
+	return &HTTPError{Status: http.StatusBadRequest, Message: "malformed upload", Err: err}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// multipartBody builds a multipart upload of n files, each size bytes long.
+func multipartBody(t *testing.T, n, size int) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		fw, err := mw.CreateFormFile("file", fmt.Sprintf("f%d.txt", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(strings.Repeat("x", size)))
+	}
+	mw.Close()
+	return &buf, mw.FormDataContentType()
+}
+
+func TestEnforceMultipart(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      int
+		size       int
+		chunked    bool
+		wantStatus int
+		wantSaved  int
+	}{
+		{"within limits", 3, 10, false, http.StatusCreated, 3},
+		{"too many parts", 5, 10, false, http.StatusBadRequest, 3},
+		{"declared too large", 1, 2048, false, http.StatusRequestEntityTooLarge, 0},
+		{"undeclared too large", 1, 2048, true, http.StatusRequestEntityTooLarge, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			h := EnforceMultipart(1024, 3)(uploadHandler(dir, time.Minute))
+			body, contentType := multipartBody(t, tt.files, tt.size)
+			req := httptest.NewRequest(http.MethodPost, "/upload", body)
+			req.Header.Set("Content-Type", contentType)
+			if tt.chunked {
+				req.ContentLength = -1
+				req.Body = io.NopCloser(body)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != tt.wantSaved {
+				t.Errorf("saved %d files, want %d", len(entries), tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestUploadStalled(t *testing.T) {
+	logs := captureLog(t)
+	oldInterval := uploadProgressInterval
+	uploadProgressInterval = 0
+	t.Cleanup(func() { uploadProgressInterval = oldInterval })
+
+	tests := []struct {
+		name       string
+		stall      bool
+		wantStatus int
+	}{
+		{"complete", false, http.StatusCreated},
+		{"stalled", true, http.StatusRequestTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(uploadHandler(t.TempDir(), 100*time.Millisecond))
+			defer srv.Close()
+			body, contentType := multipartBody(t, 1, 1024)
+			data := body.Bytes()
+			if tt.stall {
+				data = data[:len(data)/2]
+			}
+			conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n", contentType, body.Len())
+			conn.Write(data)
+
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatalf("no response: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+	if !strings.Contains(logs.String(), "upload progress") {
+		t.Errorf("no progress logged; log: %q", logs.String())
+	}
+}
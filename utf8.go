@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxUTF8CheckBytes is the largest text body RequireUTF8 will buffer.
+const maxUTF8CheckBytes = 10 << 20
+
+// RequireUTF8 rejects text and JSON request bodies that aren't valid UTF-8
+// with a 400, before they can cause subtle bugs further down. The body is
+// buffered to check it and then handed on intact. Binary content types, and
+// text declaring a charset other than UTF-8, are passed through unchecked.
+// Text bodies over 10MB get a 413.
+func RequireUTF8(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody || !isUTF8Text(r.Header.Get("Content-Type")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxUTF8CheckBytes+1))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "could not read request body")
+			return
+		}
+		if len(body) > maxUTF8CheckBytes {
+			writeLimitError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		if !utf8.Valid(body) {
+			writeError(w, r, http.StatusBadRequest, "request body is not valid UTF-8")
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// isUTF8Text reports whether a body of type ct is text that should be UTF-8.
+func isUTF8Text(ct string) bool {
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	if cs, ok := params["charset"]; ok && !strings.EqualFold(cs, "utf-8") {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "application/x-www-form-urlencoded",
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
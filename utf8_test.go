@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireUTF8(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"valid json", "application/json", `{"name":"café ☕"}`, http.StatusOK},
+		{"invalid json", "application/json", "{\"name\":\"caf\xe9\"}", http.StatusBadRequest},
+		{"invalid text", "text/plain; charset=utf-8", "\xff\xfe", http.StatusBadRequest},
+		{"invalid +json", "application/vnd.api+json", "\xc3\x28", http.StatusBadRequest},
+		{"latin-1 declared", "text/plain; charset=iso-8859-1", "caf\xe9", http.StatusOK},
+		{"binary skipped", "application/octet-stream", "\xff\xd8\xff\xe0", http.StatusOK},
+		{"image skipped", "image/png", "\x89PNG\xff", http.StatusOK},
+		{"no content type", "", "\xff", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RequireUTF8(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && got != tt.body {
+				t.Errorf("handler read %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
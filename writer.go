@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// bufferedWriter holds back a response so middleware can inspect or rewrite
+// it before anything reaches the client. Once more than limit bytes have
+// been written (limit <= 0 means no limit) it gives up and streams the rest
+// straight through, as it does when the handler flushes.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	limit     int
+	streaming bool
+}
+
+func newBufferedWriter(w http.ResponseWriter, limit int) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w, limit: limit}
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.streaming {
+		bw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if bw.status == 0 {
+		bw.status = code
+	}
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	if !bw.streaming && bw.limit > 0 && bw.buf.Len()+len(p) > bw.limit {
+		if err := bw.release(); err != nil {
+			return 0, err
+		}
+	}
+	if bw.streaming {
+		return bw.ResponseWriter.Write(p)
+	}
+	return bw.buf.Write(p)
+}
+
+// Flush switches to streaming, since a handler that flushes wants the
+// client to see its output now.
+func (bw *bufferedWriter) Flush() {
+	bw.release()
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (bw *bufferedWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// Status returns the status the handler set, defaulting to 200.
+func (bw *bufferedWriter) Status() int {
+	if bw.status == 0 {
+		return http.StatusOK
+	}
+	return bw.status
+}
+
+// Wrote reports whether the handler has called WriteHeader or Write since
+// the writer was created or last Reset. A handler that gave up without
+// writing, e.g. because its client went away, hasn't.
+func (bw *bufferedWriter) Wrote() bool {
+	return bw.status != 0
+}
+
+// Buffered reports whether the whole response is still held in the buffer.
+func (bw *bufferedWriter) Buffered() bool {
+	return !bw.streaming
+}
+
+// Body returns the buffered response body.
+func (bw *bufferedWriter) Body() []byte {
+	return bw.buf.Bytes()
+}
+
+// Reset discards the buffered status and body, so the caller can write a
+// different response in their place.
+func (bw *bufferedWriter) Reset() {
+	bw.status = 0
+	bw.buf.Reset()
+}
+
+// release sends the status and anything buffered so far, and passes every
+// later write straight through.
+func (bw *bufferedWriter) release() error {
+	if bw.streaming {
+		return nil
+	}
+	bw.streaming = true
+	bw.ResponseWriter.WriteHeader(bw.Status())
+	_, err := bw.buf.WriteTo(bw.ResponseWriter)
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ZipDir returns a Handler streaming every file under dir as a zip archive
+// download. The archive is written straight to the client rather than built
+// in memory. Symlinks are followed only when they point inside dir.
+func ZipDir(dir, filename string) Handler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", contentDisposition(sanitizeFilename(filename)))
+		flusher, _ := w.(http.Flusher)
+
+		zw := zip.NewWriter(w)
+		err = fs.WalkDir(os.DirFS(root), ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := r.Context().Err(); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if d.Type()&fs.ModeSymlink != 0 {
+				target, err := filepath.EvalSymlinks(path)
+				if err != nil || !withinDir(root, target) {
+					return nil
+				}
+				path = target
+			}
+			if err := addZipFile(zw, name, path); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = zw.Close()
+		}
+
+		// The response has already started, so all we can do about a
+		// failure now is log it; the client sees a truncated archive.
+		if err != nil && r.Context().Err() == nil {
+			log.Printf("[%s] zip %s: %v", RequestID(r), dir, err)
+		}
+		return nil
+	}
+}
+
+// addZipFile copies the regular file at path into zw as name. Anything that
+// isn't a regular file is skipped.
+func addZipFile(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Method = zip.Deflate
+	zf, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(zf, f)
+	return err
+}
+
+// withinDir reports whether path is dir or inside it. Both must be clean,
+// absolute and free of symlinks.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestZipDir(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "alpha",
+		"sub/b.txt":     "bravo",
+		"sub/deep/c.md": "charlie",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("hunter2"), 0o644)
+	os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link.txt"))
+	os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "escape.txt"))
+
+	rec := httptest.NewRecorder()
+	ZipDir(dir, "files.zip").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files.zip", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q, want application/zip", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="files.zip"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a zip: %v", err)
+	}
+	got := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(b)
+	}
+
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		present bool
+	}{
+		{"top-level file", "a.txt", "alpha", true},
+		{"nested file", "sub/b.txt", "bravo", true},
+		{"deeply nested file", "sub/deep/c.md", "charlie", true},
+		{"symlink inside root", "link.txt", "alpha", true},
+		{"symlink escaping root", "escape.txt", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, ok := got[tt.entry]
+			if ok != tt.present || content != tt.want {
+				t.Errorf("entry %q = %q (present %v), want %q (present %v); entries %v",
+					tt.entry, content, ok, tt.want, tt.present, keys(got))
+			}
+		})
+	}
+}